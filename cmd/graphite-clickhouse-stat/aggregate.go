@@ -1,17 +1,16 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"strings"
-	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/msaf1980/go-clipper"
 	"github.com/msaf1980/graphite-clickhouse-stat/pkg/aggregate"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/reader"
 	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
 	"github.com/msaf1980/graphite-clickhouse-stat/pkg/utils"
 )
@@ -25,11 +24,16 @@ type AggConfig struct {
 	IndexSort aggregate.IndexSort
 	// IndexKey  aggregate.AggSortKey
 
-	InFile  string
+	GroupBy      aggregate.GroupBy
+	SplitTargets []bool
+
+	Inputs  stringsValue
 	OutFile string
+	State   string
+	Samples string
 
-	From  time.Time
-	Until time.Time
+	Workers int
+	Reader  ReaderConfig
 }
 
 var aggConfig AggConfig
@@ -61,7 +65,11 @@ func printQueryStatHeader() {
 }
 
 func printLabel(label aggregate.LabelKey) {
-	fmt.Printf("%16s | %15s | %-165s |\n", label.DurationLabel, label.OffsetLabel, label.RequestType)
+	requestType := label.RequestType
+	if label.Group != "" {
+		requestType += " {" + label.Group + "}"
+	}
+	fmt.Printf("%16s | %15s | %-165s |\n", label.DurationLabel, label.OffsetLabel, requestType)
 }
 
 func printQueries(queries []aggregate.StatQuery) {
@@ -125,10 +133,41 @@ func printIndexes(idxs []*aggregate.StatIndexAggNode, n int, indexSort aggregate
 		printAggNode("metrics", &s.Metrics, 2)
 		printAggNode("read_rows", &s.ReadRows, 2)
 		printAggNode("read_bytes", &s.ReadBytes, 2)
+		printErrorClasses(s.ErrorClasses)
 		printFooter()
 	}
 }
 
+// printErrorClasses print failed queries of group by error class
+func printErrorClasses(errs []*aggregate.ErrorNode) {
+	if len(errs) == 0 {
+		return
+	}
+	printSmallFooter()
+	for _, e := range errs {
+		fmt.Printf("%16s | %6s | %15s | %32s | %s\n", "errors", e.Query, utils.FormatInt64(e.N), e.SampleId, e.Class)
+	}
+}
+
+func printTagTerms(terms []*aggregate.TagTermNode, n int) {
+	if n < len(terms) {
+		terms = terms[:n]
+	}
+	printFooter()
+	fmt.Printf("%16s | %6s | %15s | %15s | %15s | %15s | %32s | %s\n",
+		"N", "err%", "read_rows", "read_bytes", "max_read_rows", "time", "sample req id", "term",
+	)
+	printFooter()
+	for _, t := range terms {
+		fmt.Printf("%16s | %6s | %15s | %15s | %15s | %15s | %32s | %s\n",
+			utils.FormatInt64(t.N), utils.FormatPcnt(float64(t.Errors)/float64(t.N)*100),
+			utils.FormatNumber(t.ReadRows), utils.FormatBytes(t.ReadBytes), utils.FormatNumber(t.MaxReadRows),
+			utils.FormatFloat64(t.Time, 2), t.SampleId, t.Term,
+		)
+	}
+	printFooter()
+}
+
 func printRequests(qs []*aggregate.StatRequestAggNode, n int, sort aggregate.RequestSort, key aggregate.AggSortKey) {
 	// aggregate.SortIndexAgg(idxs, indexSort, key)
 	if n < len(qs) {
@@ -146,6 +185,7 @@ func printRequests(qs []*aggregate.StatRequestAggNode, n int, sort aggregate.Req
 		printSmallFooter()
 		printAggNode("qtimes", &s.QueryTimes, 2)
 		printAggNode("rtimes", &s.RequestTimes, 2)
+		printAggNode("ptimes", &s.ParseTimes, 2)
 		if s.IndexErrorsPcnt < 100.0 {
 			printAggNodeZ("metrics", &s.Metrics, 2)
 		} else {
@@ -162,21 +202,23 @@ func printRequests(qs []*aggregate.StatRequestAggNode, n int, sort aggregate.Req
 		printAggNode("index_read_bytes", &s.IndexReadBytes, 2)
 		printAggNode("data_read_rows", &s.DataReadRows, 2)
 		printAggNode("data_read_bytes", &s.DataReadBytes, 2)
+		printErrorClasses(s.ErrorClasses)
 		printFooter()
 	}
 }
 
-func loadAggStat(n int, sort aggregate.RequestSort, key aggregate.AggSortKey, inPath string, from, until int64) (*aggregate.StatAggSum, error) {
+// loadAggStat read logs (or aggregated json) and return aggregated summary with sample and error requests raw lines (if read with lines)
+func loadAggStat(n int, sort aggregate.RequestSort, key aggregate.AggSortKey, groupBy aggregate.GroupBy, splitTargets bool, inPaths []string, instance, statePath string, cfg reader.Config) (*aggregate.StatAggSum, map[string][]string, error) {
 	var (
-		in         io.ReadCloser
 		err        error
 		aggStatSum *aggregate.StatAggSum
 	)
-	if inPath == "" {
-		in = os.Stdin
-	} else if strings.HasSuffix(inPath, ".json") {
+	if len(inPaths) == 1 && strings.HasSuffix(inPaths[0], ".json") {
+		if cfg.Lines {
+			return nil, nil, errors.New("samples can't be extracted from aggregated json")
+		}
 		var b []byte
-		if b, err = os.ReadFile(inPath); err == nil {
+		if b, err = os.ReadFile(inPaths[0]); err == nil {
 			var aggSum aggregate.StatAggSumSlice
 			if err = json.Unmarshal(b, &aggSum); err == nil {
 				aggStatSum = aggregate.NewAggSummary()
@@ -198,50 +240,86 @@ func loadAggStat(n int, sort aggregate.RequestSort, key aggregate.AggSortKey, in
 					aggs = append(aggs, req)
 					aggStatSum.Requests[label] = aggs
 				}
+				aggStatSum.TagTerms = aggSum.TagTerms
+				aggregate.SortTagTerms(aggStatSum.TagTerms)
 			}
 		}
-		return aggStatSum, err
-	} else {
-		if in, err = os.Open(inPath); err != nil {
-			return nil, err
-		}
-		defer in.Close()
+		return aggStatSum, nil, err
 	}
 
-	queries := make(map[string]*stat.Stat)
-	var logEntry map[string]interface{}
+	if statePath != "" {
+		return loadAggStatIncremental(inPaths, instance, statePath, groupBy, splitTargets, cfg)
+	}
 
-	statSum := aggregate.NewStatSummary()
+	r, err := openInput(inPaths, instance, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
 
-	scanner := bufio.NewScanner(in)
-	for scanner.Scan() {
-		stat.ResetLogEntry(logEntry)
-		line := scanner.Bytes()
-		err := json.Unmarshal(line, &logEntry)
-		if err == nil {
-			id := stat.LogEntryProcess(logEntry, queries)
-			if id != "" {
-				stat := queries[id]
-
-				add := true
-				if from > 0 && stat.TimeStamp < from {
-					add = false
-				}
-				if add && until > 0 && stat.TimeStamp >= until {
-					add = false
-				}
-				if add {
-					statSum.Append(stat)
-				}
+	statSum := aggregate.NewStatSummary()
+	statSum.GroupBy = groupBy
+	statSum.SplitTargets = splitTargets
 
-				delete(queries, id)
-			}
-		}
+	for r.Next(context.Background()) {
+		statSum.Append(r.Stat())
+	}
+	if err = r.finish(); err != nil {
+		return nil, nil, err
 	}
 
 	aggStatSum = statSum.Aggregate()
 
-	return aggStatSum, nil
+	return aggStatSum, statSum.SampleLines(), nil
+}
+
+// loadAggStatIncremental read growing log from last checkpoint and add new requests to summary from state file
+func loadAggStatIncremental(inPaths []string, instance, statePath string, groupBy aggregate.GroupBy, splitTargets bool, cfg reader.Config) (*aggregate.StatAggSum, map[string][]string, error) {
+	if len(inPaths) != 1 {
+		return nil, nil, errors.New("state require single input log file")
+	}
+	state, err := loadAggState(statePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if state.Checkpoint != nil && !state.Summary.GroupBy.Equal(groupBy) {
+		return nil, nil, fmt.Errorf("state is grouped by [%s], can't be continued with group by [%s]", state.Summary.GroupBy.String(), groupBy.String())
+	}
+	if state.Checkpoint != nil && state.Summary.SplitTargets != splitTargets {
+		return nil, nil, fmt.Errorf("state split targets is %t, can't be continued with split targets %t", state.Summary.SplitTargets, splitTargets)
+	}
+	state.Summary.GroupBy = groupBy
+	state.Summary.SplitTargets = splitTargets
+
+	in, cp, err := reader.OpenCheckpoint(inPaths[0], state.Checkpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cp.Gap {
+		fmt.Fprintf(os.Stderr, "WARN: %s is rotated, but rotated file is not found, lines after previous run are lost\n", inPaths[0])
+	}
+	cfg.Queries = cp.Queries
+	r := &logInput{Reader: reader.New(in, withInstances(cfg, instance, inPaths)), names: inPaths, close: func() { in.Close() }}
+	defer r.Close()
+
+	for r.Next(context.Background()) {
+		state.Summary.Append(r.Stat())
+	}
+	if err = r.finish(); err != nil {
+		return nil, nil, err
+	}
+
+	aggStatSum, sampleLines := state.Summary.Aggregate(), state.Summary.SampleLines()
+
+	cp.Queries = r.Queries()
+	state.Checkpoint = cp
+	// bound state size, percentiles of restored summary are approximate
+	state.Summary.Compact(aggregate.MaxStateSamples)
+	if err = state.save(statePath); err != nil {
+		return nil, nil, err
+	}
+
+	return aggStatSum, sampleLines, nil
 }
 
 func aggRun() error {
@@ -264,21 +342,25 @@ func aggRun() error {
 		return errors.New("only json supported for out")
 	}
 
-	var (
-		from  int64
-		until int64
-	)
-	if !aggConfig.From.IsZero() {
-		from = aggConfig.From.UnixNano()
+	cfg, err := aggConfig.Reader.config(reader.Config{Workers: aggConfig.Workers, Lines: aggConfig.Samples != ""})
+	if err != nil {
+		return err
 	}
-	if !aggConfig.Until.IsZero() {
-		until = aggConfig.Until.UnixNano()
+	for _, name := range aggConfig.GroupBy {
+		if err = checkDimension(name, cfg.Processor.Dimensions); err != nil {
+			return err
+		}
 	}
 
-	aggStatSum, err := loadAggStat(aggConfig.Top, aggConfig.Sort, aggConfig.Key, aggConfig.InFile, from, until)
+	aggStatSum, sampleLines, err := loadAggStat(aggConfig.Top, aggConfig.Sort, aggConfig.Key, aggConfig.GroupBy, len(aggConfig.SplitTargets) > 0, aggConfig.Inputs, aggConfig.Reader.Instance, aggConfig.State, cfg)
 	if err != nil {
 		return err
 	}
+	if aggConfig.Samples != "" {
+		if err = writeSampleLines(aggConfig.Samples, sampleLines); err != nil {
+			return err
+		}
+	}
 
 	if aggConfig.OutFile == "" {
 		// Index queries
@@ -321,6 +403,13 @@ func aggRun() error {
 			printRequests(qs, aggConfig.Top, aggConfig.Sort, aggConfig.Key)
 		}
 		printEndline()
+
+		if len(aggStatSum.TagTerms) > 0 {
+			// Tagged index queries by tag terms
+			printReport("Tag terms", "index", "read_rows", aggConfig.Top)
+			printTagTerms(aggStatSum.TagTerms, aggConfig.Top)
+			printEndline()
+		}
 		return nil
 	} else {
 		var b []byte
@@ -339,14 +428,19 @@ func registerAggregateCmd(registry *clipper.Registry) {
 
 	aggCommand.AddValue("sort", "s", &aggConfig.Sort, false, "aggregate top sort by ("+strings.Join(aggregate.RequestSortStrings(), " | ")+") ")
 	aggCommand.AddValue("key", "k", &aggConfig.Key, false, "aggregate top key ("+strings.Join(aggregate.SortKeyStrings(), " | ")+") ")
+	aggCommand.AddValue("group-by", "g", &aggConfig.GroupBy, true, "split aggregated stat by dimensions ("+strings.Join(aggregate.GroupByStrings(), " | ")+" | "+stat.DimensionPrefix+"name), comma-separated or repeatable")
+	aggCommand.AddMultiFlag("split-targets", "", &aggConfig.SplitTargets, "split render requests per target (index and data queries are linked to targets by from/until and found metrics)")
 
 	// aggCommand.AddValue("index-sort", "S", &aggConfig.IndexSort, false, "aggregate top sort by ("+strings.Join(aggregate.IndexSortStrings(), " | ")+") ")
 	// aggCommand.AddValue("index-key", "K", &aggConfig.IndexKey, false, "aggregate top key ("+strings.Join(aggregate.SortKeyStrings(), " | ")+") ")
 
-	aggCommand.AddString("input", "i", "", &aggConfig.InFile, "input log/json file or stdin")
+	aggCommand.AddValue("input", "i", &aggConfig.Inputs, true, "input log files, globs or directories, merged by timestamp, or single json file (repeatable, stdin if not set, gzip, zstd, bzip2 and xz compressed logs are detected)")
 
 	aggCommand.AddString("output", "o", "", &aggConfig.OutFile, "output json file")
+	aggCommand.AddString("state", "S", "", &aggConfig.State, "state file for incremental aggregate of growing log (read offset, in-flight requests and summary); state keeps up to 1024 deterministically sampled values per group field, so percentiles of previous runs are approximate (counts, min and max are exact); on log rotation the rest of rotated file is read, if it's found near log uncompressed (like log.1), else lines after previous run are lost")
+	aggCommand.AddString("samples", "", "", &aggConfig.Samples, "write raw log lines of sample and error requests to NDJSON file or directory (file per request, named instance_id or id, if path is directory or ends with /); with state only requests from current run are written")
+
+	aggCommand.AddInt("workers", "w", 1, &aggConfig.Workers, "parallel log parse workers")
+	aggConfig.Reader.register(aggCommand)
 
-	aggCommand.AddTime("from", "f", time.Time{}, &aggConfig.From, dateTimeLayout, "start time (UTC)")
-	aggCommand.AddTime("until", "u", time.Time{}, &aggConfig.Until, dateTimeLayout, "end time (UTC)")
 }
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/msaf1980/go-clipper"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/reader"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/utils"
+)
+
+type ExplainConfig struct {
+	Id      string
+	Verbose []bool
+	Inputs  stringsValue
+	Workers int
+	Reader  ReaderConfig
+}
+
+var explainConfig ExplainConfig
+
+// explainEntry is a log entry of explained request
+type explainEntry struct {
+	pos   reader.LinePos
+	ts    int64 // 0 if timestamp is invalid
+	entry stat.Entry
+	line  []byte
+}
+
+var footerExplain = headLine(160, '-')
+
+func printExplainHeader(verbose int) {
+	fmt.Println(footerExplain)
+	fmt.Printf("%10s | %5s | %-32s | %s\n", "offset", "level", "logger/message", "details")
+	if verbose > 0 {
+		fmt.Printf("%10s | %5s | %-32s | %s\n", "", "", "", "log line")
+	}
+	fmt.Println(footerExplain)
+}
+
+// explainQuery return clickhouse query details, table, days and time range are from request stat (matched by query id)
+func explainQuery(e *stat.Entry, s *stat.Stat, loc *time.Location) string {
+	var sb strings.Builder
+	kind := "query"
+	if s != nil && e.QueryId != "" {
+		for _, q := range s.Index {
+			if q.QueryId == e.QueryId {
+				kind = "index " + q.Table
+				if q.Days > 0 {
+					kind += " days " + utils.FormatInt(q.Days)
+				}
+				break
+			}
+		}
+		for _, q := range s.Data {
+			if q.QueryId == e.QueryId {
+				kind = "data " + q.Table
+				if q.From > 0 && q.Until > 0 {
+					kind += " " + time.Unix(q.From, 0).In(loc).Format("2006-01-02 15:04:05") +
+						" - " + time.Unix(q.Until, 0).In(loc).Format("2006-01-02 15:04:05") +
+						" (" + utils.FormatTruncSeconds(q.Until-q.From) + ")"
+				}
+				break
+			}
+		}
+	}
+	fmt.Fprintf(&sb, "%s, read_rows %s, read_bytes %s, time %.3f, query_id %s",
+		kind, utils.FormatNumber(int64(e.ReadRows)), utils.FormatBytes(int64(e.ReadBytes)), float64(e.Time), e.QueryId,
+	)
+	if e.Query != "" {
+		sb.WriteString("\n")
+		fmt.Fprintf(&sb, "%10s | %5s | %-32s | %s", "", "", "", string(e.Query))
+	}
+	return sb.String()
+}
+
+// explainDetails return log entry details for waterfall
+func explainDetails(e *stat.Entry, s *stat.Stat, loc *time.Location) string {
+	var details string
+	switch {
+	case e.Message == "query" && e.Query != "":
+		details = explainQuery(e, s, loc)
+	case e.Message == "finder":
+		if e.FindCached.Value {
+			details = "cache hit"
+		} else if e.FindCached.Set {
+			details = "cache miss"
+		}
+		details += ", metrics " + utils.FormatNumber(int64(e.Metrics))
+		if e.GetCache != "" {
+			details += ", get_cache " + e.GetCache
+		}
+		if e.SetCache != "" {
+			details += ", set_cache " + e.SetCache
+		}
+		if e.Target != "" {
+			details += ", target " + e.Target
+		}
+	case e.Target != "":
+		details = "target " + e.Target
+		if e.From > 0 && e.Until > 0 {
+			details += ", " + time.Unix(int64(e.From), 0).In(loc).Format("2006-01-02 15:04:05") +
+				" - " + time.Unix(int64(e.Until), 0).In(loc).Format("2006-01-02 15:04:05") +
+				" (" + utils.FormatTruncSeconds(int64(e.Until-e.From)) + ")"
+		}
+	case e.Message == "data_parse":
+		details = fmt.Sprintf("points %s, bytes %s, parse %.3f", utils.FormatNumber(int64(e.ReadPoints)), utils.FormatBytes(int64(e.ReadBytes)), float64(e.RuntimeNs))
+	case e.Logger == "http" && e.Message == "access":
+		details = fmt.Sprintf("status %d, time %.3f, wait %.3f, %s", int64(e.Status), float64(e.Time), float64(e.WaitSlot), e.URL)
+		if e.WaitFail.Value {
+			details += ", wait failed"
+		}
+	}
+	if e.Error != "" {
+		if details != "" {
+			details += ", "
+		}
+		details += "error: " + e.Error
+	}
+	return details
+}
+
+// printExplain print request log entries as waterfall (offsets from the first entry)
+func printExplain(entries []explainEntry, s *stat.Stat, verbose int, loc *time.Location) {
+	var start int64
+	for i := range entries {
+		if entries[i].ts > 0 {
+			start = entries[i].ts
+			break
+		}
+	}
+	for i := range entries {
+		e := &entries[i]
+		var offset string
+		if e.ts > 0 {
+			offset = fmt.Sprintf("%10.3f", float64(e.ts-start)/1e9)
+		}
+		fmt.Printf("%10s | %5s | %-32s | %s\n",
+			offset, e.entry.Level, e.entry.Logger+"/"+e.entry.Message, explainDetails(&e.entry, s, loc),
+		)
+		if verbose > 0 {
+			fmt.Printf("%10s | %5s | %-32s | %s\n", "", "", "", e.line)
+		}
+	}
+}
+
+func explainRun() error {
+	if explainConfig.Id == "" {
+		return errors.New("request id not set")
+	}
+	id := explainConfig.Id
+	verbose := len(explainConfig.Verbose)
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	cfg, err := explainConfig.Reader.config(reader.Config{Workers: explainConfig.Workers})
+	if err != nil {
+		return err
+	}
+
+	// request entries by request key (request ids from several instances can collide)
+	var keys []string
+	entries := make(map[string][]explainEntry)
+	timeLayout := cfg.Processor.TimeLayout
+	cfg.Entry = func(pos reader.LinePos, e *stat.Entry, line []byte) {
+		if e.RequestId != id {
+			return
+		}
+		key := e.Instance
+		if _, ok := entries[key]; !ok {
+			keys = append(keys, key)
+		}
+		ts, _ := timeLayout.Parse(string(e.TimeStamp))
+		entries[key] = append(entries[key], explainEntry{
+			pos: pos, ts: ts, entry: e.Copy(), line: append([]byte(nil), line...),
+		})
+	}
+
+	r, err := openInput(explainConfig.Inputs, explainConfig.Reader.Instance, cfg)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	stats := make(map[string]*stat.Stat)
+	for r.Next(ctx) {
+		if s := r.Stat(); s.Id == id {
+			stats[s.Instance] = s
+		}
+	}
+	// not completed requests
+	clock := r.Clock()
+	inFlight := make(map[string]bool)
+	for _, s := range r.InFlight(clock, 0) {
+		if _, ok := stats[s.Instance]; !ok && s.Id == id {
+			stats[s.Instance] = s
+			inFlight[s.Instance] = true
+		}
+	}
+	if err = r.finish(); err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("request_id %s not found", id)
+	}
+
+	loc, dims := explainConfig.Reader.loc, cfg.Processor.Dimensions
+
+	for _, key := range keys {
+		if key != "" {
+			fmt.Printf("instance %s\n", key)
+		}
+		s := stats[key]
+		if s != nil {
+			printHeader(3, loc, dims)
+			printStat(s.Id, s, 3, loc, dims)
+			if inFlight[key] {
+				fmt.Printf("not completed, pending %s\n", utils.FormatDuration(s.Pending(clock)/1e9, false))
+			}
+		}
+		printExplainHeader(verbose)
+		printExplain(entries[key], s, verbose, loc)
+		fmt.Println(footerExplain)
+		printEndline()
+	}
+
+	return nil
+}
+
+func registerExplainCmd(registry *clipper.Registry) {
+	explainCommand, _ := registry.RegisterWithCallback("explain", "read log and print full timeline of single request", explainRun)
+
+	explainCommand.AddString("id", "", "", &explainConfig.Id, "request id (like SampleId or ErrorId from aggregate)")
+	explainCommand.AddMultiFlag("verbose", "v", &explainConfig.Verbose, "verbose (print original log lines)")
+
+	explainCommand.AddValue("input", "i", &explainConfig.Inputs, true, "input log files, globs or directories, merged by timestamp (repeatable, stdin if not set, gzip, zstd, bzip2 and xz compressed logs are detected)")
+	explainCommand.AddInt("workers", "w", 1, &explainConfig.Workers, "parallel log parse workers")
+	explainConfig.Reader.register(explainCommand)
+
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/msaf1980/go-clipper"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/reader"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/utils"
+)
+
+// stringsValue is a repeatable string flag
+type stringsValue []string
+
+func (s *stringsValue) Set(value string, _ bool) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func (s *stringsValue) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsValue) Type() string {
+	return "strings"
+}
+
+func (s *stringsValue) Reset(i interface{}) {
+	*s = i.(stringsValue)
+}
+
+func (s *stringsValue) Get() interface{} {
+	return []string(*s)
+}
+
+// ReaderConfig is a common log read options
+type ReaderConfig struct {
+	Strict      []bool
+	Lenient     []bool
+	MaxLineSize int
+
+	From       string
+	Until      string
+	TZ         string
+	TimeLayout string
+	Format     string
+	Envelope   string
+	Instance   string
+
+	LeakTimeout time.Duration
+
+	Dimensions stringsValue
+	Where      stringsValue
+
+	RequestTypes stringsValue
+	ErrorRules   stringsValue
+
+	loc *time.Location // time zone for output (resolved --tz, set by config)
+}
+
+func (c *ReaderConfig) register(cmd *clipper.Command) {
+	cmd.AddMultiFlag("strict", "", &c.Strict, "stop on first bad log line")
+	cmd.AddMultiFlag("lenient", "", &c.Lenient, "skip bad log lines and report them at end (default)")
+	cmd.AddInt("max-line", "", reader.DefaultMaxLineSize, &c.MaxLineSize, "max log line size, longer lines are reported as bad (< 0 - unlimited)")
+
+	cmd.AddString("from", "f", "", &c.From, "start time: 2006-01-02T15:04:05 (in --tz), RFC3339 or relative (now-15m, -2h)")
+	cmd.AddString("until", "u", "", &c.Until, "end time: 2006-01-02T15:04:05 (in --tz), RFC3339 or relative (now-15m, -2h)")
+	cmd.AddString("tz", "", "UTC", &c.TZ, "time zone for output and --from/--until (UTC, Local or IANA name, like Europe/Moscow)")
+	cmd.AddString("time-layout", "", "auto", &c.TimeLayout, "log timestamp layout: "+strings.Join(stat.TimeLayoutStrings(), ", ")+" or go time layout")
+	cmd.AddString("format", "", "auto", &c.Format, "log format: "+strings.Join(stat.FormatStrings(), ", ")+" (zap json or console encoder)")
+	cmd.AddString("envelope", "", "auto", &c.Envelope, "log line envelope: "+strings.Join(reader.EnvelopeStrings(), ", ")+" (host or pod name is used as instance label)")
+	cmd.AddDuration("leak-timeout", "", stat.DefaultLeakTimeout, &c.LeakTimeout, "not completed request timeout, request with the same id after timeout is a new request")
+	cmd.AddString("instance", "", "auto", &c.Instance, "instance label for input files: auto (pod name or container id from container log path), file (file name), dir (parent directory name), none; instance field or log envelope host is preferred")
+	cmd.AddValue("dimension", "", &c.Dimensions, true, "custom request dimension: name=header:key (request_headers key) or name=field:key (top-level log field), used as "+stat.DimensionPrefix+"name (repeatable)")
+	cmd.AddValue("where", "", &c.Where, true, "filter requests by dimension value: name=value ("+strings.Join(stat.DimensionStrings(), " | ")+" | "+stat.DimensionPrefix+"name), repeatable, values of the same dimension are alternatives")
+	cmd.AddValue("request-type", "", &c.RequestTypes, true, "request type rule: type=prefix:path[;params=p1,p2] or type=regexp:re[;params=p1,p2] (url query params are extracted as request query), repeatable, checked in order before default rules")
+	cmd.AddValue("error-class", "", &c.ErrorRules, true, "query error class rule: class=regexp, repeatable, checked in order before builtin rules (ClickHouse error codes and network errors)")
+}
+
+// config return reader config with common options
+func (c *ReaderConfig) config(cfg reader.Config) (reader.Config, error) {
+	if len(c.Strict) > 0 && len(c.Lenient) > 0 {
+		return cfg, errors.New("strict and lenient modes are mutually exclusive")
+	}
+	cfg.Strict = len(c.Strict) > 0
+	cfg.MaxLineSize = c.MaxLineSize
+
+	loc, err := loadLocation(c.TZ)
+	if err != nil {
+		return cfg, err
+	}
+	c.loc = loc
+
+	timeLayout, err := stat.NewTimeLayout(c.TimeLayout, loc)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Processor = stat.NewProcessor()
+	cfg.Processor.TimeLayout = timeLayout
+	if cfg.Processor.Format, err = stat.ParseFormat(c.Format); err != nil {
+		return cfg, err
+	}
+	if c.LeakTimeout <= 0 {
+		return cfg, errors.New("leak timeout must be > 0")
+	}
+	cfg.Processor.LeakTimeout = c.LeakTimeout
+
+	if len(c.RequestTypes) > 0 {
+		rules := make([]stat.TypeRule, 0, len(c.RequestTypes)+len(stat.DefaultTypeRules))
+		for _, v := range c.RequestTypes {
+			r, err := stat.ParseTypeRule(v)
+			if err != nil {
+				return cfg, err
+			}
+			rules = append(rules, r)
+		}
+		cfg.Processor.TypeRules = append(rules, stat.DefaultTypeRules...)
+	}
+
+	for _, v := range c.ErrorRules {
+		r, err := stat.ParseErrorRule(v)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Processor.ErrorRules = append(cfg.Processor.ErrorRules, r)
+	}
+
+	for _, v := range c.Dimensions {
+		d, err := stat.ParseDimension(v)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Processor.Dimensions = append(cfg.Processor.Dimensions, d)
+	}
+	for _, v := range c.Where {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return cfg, errors.New("invalid where " + v + ", must be name=value")
+		}
+		if err = checkDimension(name, cfg.Processor.Dimensions); err != nil {
+			return cfg, err
+		}
+		if cfg.Where == nil {
+			cfg.Where = make(map[string][]string)
+		}
+		cfg.Where[name] = append(cfg.Where[name], value)
+	}
+
+	if cfg.Envelope, err = reader.ParseEnvelope(c.Envelope); err != nil {
+		return cfg, err
+	}
+	switch c.Instance {
+	case "", "auto", "file", "dir", "none":
+	default:
+		return cfg, errors.New("invalid instance label source: " + c.Instance)
+	}
+
+	now := time.Now()
+	if cfg.From, err = parseTime("from", c.From, now, loc); err != nil {
+		return cfg, err
+	}
+	if cfg.Until, err = parseTime("until", c.Until, now, loc); err != nil {
+		return cfg, err
+	}
+	if cfg.From > 0 && cfg.Until > 0 && cfg.From > cfg.Until {
+		return cfg, errors.New("from is after until")
+	}
+	return cfg, nil
+}
+
+// checkDimension check dimension name is builtin or configured custom dimension (set by --dimension)
+func checkDimension(name string, dims []stat.Dimension) error {
+	if strings.HasPrefix(name, stat.DimensionPrefix) {
+		for _, d := range dims {
+			if stat.DimensionPrefix+d.Name == name {
+				return nil
+			}
+		}
+		return errors.New("custom dimension " + name + " not configured")
+	}
+	for _, s := range stat.DimensionStrings() {
+		if s == name {
+			return nil
+		}
+	}
+	return errors.New("unknown dimension " + name)
+}
+
+// withInstances return reader config with instance labels for input files by instance label source (--instance)
+func withInstances(cfg reader.Config, instance string, files []string) reader.Config {
+	if instance == "none" {
+		return cfg
+	}
+	cfg.Instances = make([]string, len(files))
+	for i, file := range files {
+		switch instance {
+		case "file":
+			cfg.Instances[i] = reader.InstanceFromFileName(file)
+		case "dir":
+			cfg.Instances[i] = filepath.Base(filepath.Dir(file))
+		default:
+			cfg.Instances[i] = reader.InstanceFromPath(file)
+		}
+	}
+	return cfg
+}
+
+// loadLocation return time zone by name (UTC if empty)
+func loadLocation(name string) (*time.Location, error) {
+	switch strings.ToLower(name) {
+	case "", "utc":
+		return time.UTC, nil
+	case "local":
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.New("invalid time zone: " + err.Error())
+	}
+	return loc, nil
+}
+
+// parseTime parse time flag and return unix nano time (0 if not set)
+func parseTime(name, s string, now time.Time, loc *time.Location) (int64, error) {
+	t, err := utils.ParseTime(s, now, loc)
+	if err != nil {
+		return 0, errors.New(name + ": " + err.Error())
+	}
+	if t.IsZero() {
+		return 0, nil
+	}
+	return t.UnixNano(), nil
+}
+
+// logInput is a reader for opened logs
+type logInput struct {
+	*reader.Reader
+	names []string // input names by input number
+	close func()
+}
+
+func (in *logInput) Close() {
+	in.close()
+}
+
+func (in *logInput) posString(pos reader.LinePos) string {
+	if pos.Input < len(in.names) {
+		return in.names[pos.Input] + ":" + strconv.FormatInt(pos.Line, 10)
+	}
+	return strconv.FormatInt(pos.Line, 10)
+}
+
+// report print bad lines report to stderr
+func (in *logInput) report() {
+	bad := in.BadLines()
+	if len(bad) == 0 {
+		return
+	}
+	reasons := make([]stat.Reason, 0, len(bad))
+	for reason := range bad {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool { return reasons[i] < reasons[j] })
+
+	counters := in.Counters()
+	fmt.Fprintf(os.Stderr, "bad lines: %d of %d\n", counters.Bad, counters.Lines)
+	for _, reason := range reasons {
+		samples := make([]string, len(bad[reason].Samples))
+		for i, pos := range bad[reason].Samples {
+			samples[i] = in.posString(pos)
+		}
+		fmt.Fprintf(os.Stderr, "  %s: %d (lines %s)\n", reason, bad[reason].Count, strings.Join(samples, ", "))
+	}
+}
+
+// finish print bad lines report and return read error (context cancel is a normal stop)
+func (in *logInput) finish() error {
+	in.report()
+	err := in.Err()
+	if err == nil || errors.Is(err, context.Canceled) {
+		return nil
+	}
+	var lineErr *reader.LineError
+	if errors.As(err, &lineErr) {
+		return fmt.Errorf("%s: %w", in.posString(lineErr.Pos), lineErr.Err)
+	}
+	return err
+}
+
+// openInput open logs (files, globs or directories, stdin if empty) and return reader with timestamp merged entries
+func openInput(paths []string, instance string, cfg reader.Config) (*logInput, error) {
+	if len(paths) == 0 {
+		in, err := reader.Open("")
+		if err != nil {
+			return nil, err
+		}
+		return &logInput{Reader: reader.New(in, cfg), names: []string{"stdin"}, close: func() { in.Close() }}, nil
+	}
+
+	files, err := reader.ExpandPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	ins, err := reader.OpenFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	readers := make([]io.Reader, len(ins))
+	for i, in := range ins {
+		readers[i] = in
+	}
+	closeInput := func() {
+		for _, in := range ins {
+			in.Close()
+		}
+	}
+
+	return &logInput{Reader: reader.NewMerge(readers, withInstances(cfg, instance, files)), names: files, close: closeInput}, nil
+}
+
+// openFollow open single log file for follow (like tail -F, from the end of file or from the start) and return reader, stopped by context cancel
+func openFollow(ctx context.Context, paths []string, instance string, fromStart bool, cfg reader.Config) (*logInput, error) {
+	if cfg.Workers > 1 {
+		return nil, errors.New("follow mode is sequential, workers must be 1")
+	}
+	files, err := reader.ExpandPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) != 1 {
+		return nil, errors.New("follow mode require single input file")
+	}
+	fl, err := reader.NewFollower(ctx, files[0], reader.DefaultPollInterval, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logInput{Reader: reader.New(fl, withInstances(cfg, instance, files)), names: files, close: func() { fl.Close() }}, nil
+}
+
+// openReader open inputs (or single followed log file) and return reader
+func openReader(ctx context.Context, paths []string, instance string, follow, fromStart bool, cfg reader.Config) (*logInput, error) {
+	if follow {
+		return openFollow(ctx, paths, instance, fromStart, cfg)
+	}
+	return openInput(paths, instance, cfg)
+}
+
+// signalContext return context, canceled on interrupt or terminate
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
@@ -16,6 +16,7 @@ func main() {
 	registerPrintCmd(registry)
 	registerTopCmd(registry)
 	registerAggregateCmd(registry)
+	registerExplainCmd(registry)
 
 	if _, err := registry.Parse(os.Args[1:]); err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
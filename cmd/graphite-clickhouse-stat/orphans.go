@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/utils"
+)
+
+var footerOrphans = headLine(160, '-')
+
+func printOrphansHeader(title string, verbose int, loc *time.Location) {
+	fmt.Println(footerOrphans)
+	fmt.Printf("      %s\n", title)
+	fmt.Println(footerOrphans)
+	fmt.Printf("%19s | %10s | %10s | %16s | %32s | %7s | %10s | %10s | %10s | %s\n",
+		"start ("+time.Now().In(loc).Format("MST")+")", "pending", "idle", "type", "request_id",
+		"queries", "read_rows", "iread_rows", "dread_rows", "username",
+	)
+	if verbose > 0 {
+		fmt.Println(footerOrphans)
+		fmt.Printf("%19s | %3s | %10s | %10s | %s\n",
+			"index days", "", "duration", "offset", "query",
+		)
+	}
+	if verbose > 2 {
+		fmt.Println(footerOrphans)
+		printTimelineHeader()
+	}
+	fmt.Println(footerOrphans)
+}
+
+// printOrphan print not completed request with partial stat, pending (since start) and idle (since last entry) durations at log time now
+func printOrphan(s *stat.Stat, now int64, verbose int, loc *time.Location) {
+	fmt.Printf("%19s | %10s | %10s | %16s | %32s | %7d | %10s | %10s | %10s | %s\n",
+		time.Unix(s.StartTimeStamp()/1e9, 0).In(loc).Format("2006-01-02 15:04:05"),
+		utils.FormatDuration(s.Pending(now)/1e9, false), utils.FormatDuration((now-s.TimeStamp)/1e9, false),
+		s.RequestType, s.Id,
+		len(s.Index)+len(s.Data),
+		utils.FormatNumber(s.ReadRows), utils.FormatNumber(s.IndexReadRows), utils.FormatNumber(s.DataReadRows),
+		s.Username,
+	)
+	printStatDetails(s, verbose)
+}
+
+func printOrphans(title string, stats []*stat.Stat, now int64, verbose int, loc *time.Location) {
+	printOrphansHeader(title, verbose, loc)
+	for _, s := range stats {
+		printOrphan(s, now, verbose, loc)
+	}
+}
@@ -1,16 +1,13 @@
 package main
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
-	"io"
-	"os"
+	"strings"
 	"time"
 
-	"github.com/goccy/go-json"
-
 	"github.com/msaf1980/go-clipper"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/reader"
 	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
 	"github.com/msaf1980/graphite-clickhouse-stat/pkg/utils"
 )
@@ -26,16 +23,19 @@ type PrintConfig struct {
 	// TODO: increment flag
 	Verbose []bool
 
-	From  time.Time
-	Until time.Time
+	Inputs    stringsValue
+	Follow    []bool
+	FromStart []bool
+	Workers   int
+	Reader    ReaderConfig
 
-	File string
+	Orphans []bool
 }
 
 var printConfig PrintConfig
 
 var (
-	footerPrint      = headLine(214, '-')
+	footerPrint      = headLine(225, '-')
 	labelFooterPrint = headLine(204, '=')
 )
 
@@ -47,13 +47,13 @@ func printLabelFooter() {
 	fmt.Println(labelFooterPrint)
 }
 
-func printHeader(verbose int) {
+func printHeader(verbose int, loc *time.Location, dims []stat.Dimension) {
 	printFooter()
-	fmt.Printf("%19s | %3s | %10s | %10s | %10s |%s| %10s | %10s | %16s | %32s | %7s | %8s | %8s | %10s | %10s | %6s | %s\n",
-		"timestamp (UTC)", "S", "rtime", "wtime", "qtime", "W",
+	fmt.Printf("%19s | %3s | %10s | %10s | %10s |%s| %10s | %10s | %16s | %32s | %7s | %8s | %8s | %8s | %10s | %10s | %6s | %s\n",
+		"timestamp ("+time.Now().In(loc).Format("MST")+")", "S", "rtime", "wtime", "qtime", "W",
 		"read_rows", "read_bytes",
-		"type", "request_id", "metrics", "points", "size",
-		"iread_rows", "dread_rows", "mdur", "username",
+		"type", "request_id", "metrics", "points", "size", "ptime",
+		"iread_rows", "dread_rows", "mdur", "username"+dimensionsHeader(dims),
 	)
 	if verbose > 0 {
 		printFooter()
@@ -65,12 +65,38 @@ func printHeader(verbose int) {
 		printFooter()
 		// index/data stat
 		fmt.Printf("%19s | %3s | %10s | %10s | %10s |%s| %10s | %10s | %51s | %-29s | %s\n",
-			"index_days", "", "duration", "offset", "time", "S", "read_rows", "read_bytes", "query_id", "table", "error",
+			"index_days", "", "duration", "offset", "time", "S", "read_rows", "read_bytes", "query_id", "table", "error/tag1/details",
 		)
 	}
+	if verbose > 2 {
+		printFooter()
+		printTimelineHeader()
+	}
 	printFooter()
 }
 
+func printTimelineHeader() {
+	fmt.Printf("%19s | %3s | %10s | %10s | %s\n",
+		"timeline", "", "offset", "stage", "table",
+	)
+}
+
+// printTimeline print request timeline: stages offsets (seconds) from request start
+func printTimeline(s *stat.Stat) {
+	for _, e := range s.Timeline() {
+		var table string
+		switch e.Stage {
+		case stat.StageIndex:
+			table = s.Index[e.N].Table
+		case stat.StageData:
+			table = s.Data[e.N].Table
+		}
+		fmt.Printf("%19s | %3s | %10.3f | %10s | %s\n",
+			"", "", float64(e.Offset(s))/1e9, e.Stage.String(), table,
+		)
+	}
+}
+
 func headLine(n int, c byte) string {
 	out := make([]byte, 0, n)
 	for i := 0; i < n; i++ {
@@ -79,20 +105,85 @@ func headLine(n int, c byte) string {
 	return string(out)
 }
 
-func printStat(id string, s *stat.Stat, verbose int) {
+// dimensionsHeader return custom dimensions columns header (after username)
+func dimensionsHeader(dims []stat.Dimension) string {
+	var sb strings.Builder
+	for _, d := range dims {
+		sb.WriteString(" | ")
+		sb.WriteString(d.Name)
+	}
+	return sb.String()
+}
+
+// dimensionsColumns return custom dimensions columns of request (after username)
+func dimensionsColumns(s *stat.Stat, dims []stat.Dimension) string {
+	var sb strings.Builder
+	for _, d := range dims {
+		sb.WriteString(" | ")
+		sb.WriteString(s.Dimensions[d.Name])
+	}
+	return sb.String()
+}
+
+func printStat(id string, s *stat.Stat, verbose int, loc *time.Location, dims []stat.Dimension) {
 
 	fmt.Printf("%19s | %3d | %10.2f | %10.2f | %10.2f |%s| %10s | %10s | %16s | %32s"+ // last - id
-		" | %7s | %8s | %8s | %10s | %10s | %6s | %s\n", // metrics, points, bytes, read_rows, read_bytes
-		time.Unix(s.TimeStamp/1e9, 0).UTC().Format("2006-01-02 15:04:05"),
+		" | %7s | %8s | %8s | %8.3f | %10s | %10s | %6s | %s\n", // metrics, points, bytes, parse time, read_rows, read_bytes
+		time.Unix(s.TimeStamp/1e9, 0).In(loc).Format("2006-01-02 15:04:05"),
 		s.RequestStatus,
 		s.RequestTime, s.WaitTime, s.QueryTime, s.WaitStatus.String(),
 		utils.FormatNumber(s.ReadRows), utils.FormatBytes(s.ReadBytes),
 		s.RequestType, id,
-		utils.FormatNumber(s.Metrics), utils.FormatNumber(s.Points), utils.FormatBytes(s.Bytes),
+		utils.FormatNumber(s.Metrics), utils.FormatNumber(s.Points), utils.FormatBytes(s.Bytes), s.DataParseTime,
 		utils.FormatNumber(s.IndexReadRows), utils.FormatNumber(s.DataReadRows),
-		utils.FormatDuration(s.MaxDuration(), false), s.Username,
+		utils.FormatDuration(s.MaxDuration(), false), s.Username+dimensionsColumns(s, dims),
 	)
+	printStatDetails(s, verbose)
+}
+
+// statContext return grafana and carbonapi request context (empty if not set)
+func statContext(s *stat.Stat) string {
+	var ctx []string
+	if s.GrafanaOrgId != "" {
+		ctx = append(ctx, "org "+s.GrafanaOrgId)
+	}
+	if s.DashboardId != "" {
+		ctx = append(ctx, "dashboard "+s.DashboardId)
+	}
+	if s.PanelId != "" {
+		ctx = append(ctx, "panel "+s.PanelId)
+	}
+	if s.CarbonapiUuid != "" {
+		ctx = append(ctx, "carbonapi_uuid "+s.CarbonapiUuid)
+	}
+	return strings.Join(ctx, ", ")
+}
+
+// dataQueryDetails return data query resample, scanned dates and parse details, like avg 10s, dates 2023-01-21 - 2023-01-22 (2), points 4, 148 B, parse 0.039
+func dataQueryDetails(q *stat.DataStat) string {
+	var details []string
+	if q.AggFunc != "" {
+		details = append(details, fmt.Sprintf("%s %ds", q.AggFunc, q.Step))
+	}
+	if days := q.DateDays(); days > 0 {
+		details = append(details, "dates "+time.Unix(q.DateFrom, 0).UTC().Format("2006-01-02")+" - "+
+			time.Unix(q.DateUntil, 0).UTC().Format("2006-01-02")+" ("+utils.FormatInt(days)+")")
+	}
+	if q.ExternalTable {
+		details = append(details, "metrics_list")
+	}
+	if q.Parsed {
+		details = append(details, fmt.Sprintf("points %s, %s, parse %.3f", utils.FormatNumber(q.Points), utils.FormatBytes(q.Bytes), q.ParseTime))
+	}
+	return strings.Join(details, ", ")
+}
+
+// printStatDetails print request context and queries (verbose > 0), index/data stat (verbose > 1) and timeline (verbose > 2)
+func printStatDetails(s *stat.Stat, verbose int) {
 	if verbose > 0 {
+		if ctx := statContext(s); ctx != "" {
+			fmt.Printf("%19s | %3s | %10s | %10s | %s\n", "", "", "", "context", ctx)
+		}
 		for _, q := range s.Queries {
 			var d, offset string
 			if q.From > 0 && q.Until > 0 {
@@ -110,7 +201,7 @@ func printStat(id string, s *stat.Stat, verbose int) {
 			fmt.Printf("%19s | %3s | %10s | %10s | %10.2f |%s| %10s | %10s | %51s | %-29s | %s\n",
 				utils.FormatInt(q.Days), "", "", "",
 				q.Time, q.Status.String(),
-				utils.FormatNumber(q.ReadRows), utils.FormatBytes(q.ReadBytes), q.QueryId, q.Table, q.Error,
+				utils.FormatNumber(q.ReadRows), utils.FormatBytes(q.ReadBytes), q.QueryId, q.Table, strings.TrimSpace(q.Error+" "+q.Tag1),
 			)
 		}
 		// data stat
@@ -120,14 +211,16 @@ func printStat(id string, s *stat.Stat, verbose int) {
 				d = utils.FormatTruncSeconds(q.Until - q.From)
 				offset = utils.FormatTruncSeconds(s.TimeStamp/1e9 - q.Until)
 			}
-			fmt.Printf("%19s | %3s | %10s | %10s | %10.2f |%s| %10s | %10s | %51s | %s\n",
+			fmt.Printf("%19s | %3s | %10s | %10s | %10.2f |%s| %10s | %10s | %51s | %-29s | %s\n",
 				utils.FormatInt(q.Days), "", d, offset,
 				q.Time, q.Status.String(),
-				utils.FormatNumber(q.ReadRows), utils.FormatBytes(q.ReadBytes), q.QueryId, q.Table,
+				utils.FormatNumber(q.ReadRows), utils.FormatBytes(q.ReadBytes), q.QueryId, q.Table, dataQueryDetails(&q),
 			)
 		}
 	}
-
+	if verbose > 2 {
+		printTimeline(s)
+	}
 }
 
 func printRun() error {
@@ -158,83 +251,66 @@ func printRun() error {
 		}
 	}
 
-	var (
-		in    io.ReadCloser
-		err   error
-		from  int64
-		until int64
-	)
-	if printConfig.File == "" {
-		in = os.Stdin
-	} else {
-		if in, err = os.Open(printConfig.File); err != nil {
-			return err
-		}
-		defer in.Close()
-	}
+	ctx, cancel := signalContext()
+	defer cancel()
 
-	if !printConfig.From.IsZero() {
-		from = printConfig.From.UnixNano()
+	orphans := len(printConfig.Orphans) > 0
+	cfg, err := printConfig.Reader.config(reader.Config{Workers: printConfig.Workers, Orphans: orphans})
+	if err != nil {
+		return err
 	}
-	if !printConfig.Until.IsZero() {
-		until = printConfig.Until.UnixNano()
+	r, err := openReader(ctx, printConfig.Inputs, printConfig.Reader.Instance, len(printConfig.Follow) > 0, len(printConfig.FromStart) > 0, cfg)
+	if err != nil {
+		return err
 	}
-
-	queries := make(map[string]*stat.Stat)
-	var logEntry map[string]interface{}
-
-	printHeader(len(printConfig.Verbose))
-
-	scanner := bufio.NewScanner(in)
-	for scanner.Scan() {
-		stat.ResetLogEntry(logEntry)
-		line := scanner.Bytes()
-		err := json.Unmarshal(line, &logEntry)
-		if err == nil {
-			id := stat.LogEntryProcess(logEntry, queries)
-			if id != "" {
-				stat := queries[id]
-
-				print := true
-				if from > 0 && stat.TimeStamp < from {
+	defer r.Close()
+
+	loc, dims := printConfig.Reader.loc, cfg.Processor.Dimensions
+	printHeader(len(printConfig.Verbose), loc, dims)
+
+	for r.Next(ctx) {
+		stat := r.Stat()
+
+		print := true
+		if compare {
+			if printConfig.MinRows > 0 && stat.ReadRows <= printConfig.MinRows {
+				print = false
+			} else if printConfig.MinTime > 0.0 && stat.RequestTime <= printConfig.MinTime {
+				print = false
+			} else if printConfig.IndexMinRows > 0 && stat.IndexReadRows < printConfig.IndexMinRows {
+				print = false
+			} else if printConfig.DataMinRows > 0 && stat.DataReadRows < printConfig.DataMinRows {
+				print = false
+			} else if len(validStatus) > 0 {
+				if _, ok := validStatus[stat.RequestStatus]; ok {
 					print = false
 				}
-				if print && until > 0 && stat.TimeStamp >= until {
+			} else if len(skipStatus) > 0 {
+				if _, ok := skipStatus[stat.RequestStatus]; !ok {
 					print = false
 				}
-
-				if print && compare {
-					if printConfig.MinRows > 0 && stat.ReadRows <= printConfig.MinRows {
-						print = false
-					} else if printConfig.MinTime > 0.0 && stat.RequestTime <= printConfig.MinTime {
-						print = false
-					} else if printConfig.IndexMinRows > 0 && stat.IndexReadRows < printConfig.IndexMinRows {
-						print = false
-					} else if printConfig.DataMinRows > 0 && stat.DataReadRows < printConfig.DataMinRows {
-						print = false
-					} else if len(validStatus) > 0 {
-						if _, ok := validStatus[stat.RequestStatus]; ok {
-							print = false
-						}
-					} else if len(skipStatus) > 0 {
-						if _, ok := skipStatus[stat.RequestStatus]; !ok {
-							print = false
-						}
-					}
-				}
-				if print {
-					printStat(id, stat, len(printConfig.Verbose))
-				}
-
-				delete(queries, id)
+			}
+		}
+		if print {
+			printStat(stat.Id, stat, len(printConfig.Verbose), loc, dims)
+		}
+		if orphans {
+			if leaked := r.Orphans(); len(leaked) > 0 {
+				printOrphans("leaked requests", leaked, r.Clock(), len(printConfig.Verbose), loc)
 			}
 		}
 	}
 
-	return nil
-}
+	if orphans {
+		clock := r.Clock()
+		leaked := append(r.Orphans(), r.InFlight(clock, 0)...)
+		if len(leaked) > 0 {
+			printOrphans("not completed requests", leaked, clock, len(printConfig.Verbose), loc)
+		}
+	}
 
-var dateTimeLayout = "2006-01-02T15:04:05"
+	return r.finish()
+}
 
 func registerPrintCmd(registry *clipper.Registry) {
 	printCommand, _ := registry.RegisterWithCallback("print", "read and print queries stat", printRun)
@@ -251,8 +327,11 @@ func registerPrintCmd(registry *clipper.Registry) {
 
 	printCommand.AddMultiFlag("verbose", "v", &printConfig.Verbose, "verbose")
 
-	printCommand.AddString("input", "i", "", &printConfig.File, "input log file or stdin")
+	printCommand.AddValue("input", "i", &printConfig.Inputs, true, "input log files, globs or directories, merged by timestamp (repeatable, stdin if not set, gzip, zstd, bzip2 and xz compressed logs are detected)")
+	printCommand.AddMultiFlag("follow", "F", &printConfig.Follow, "follow single log file for new lines (like tail -F, reopen after rename or truncate rotation), existing lines are skipped")
+	printCommand.AddMultiFlag("from-start", "", &printConfig.FromStart, "in follow mode read existing lines from the start of file")
+	printCommand.AddInt("workers", "w", 1, &printConfig.Workers, "parallel log parse workers")
+	printCommand.AddMultiFlag("orphans", "", &printConfig.Orphans, "print orphaned requests (leaked or not completed at end of log) with partial stat and pending duration")
+	printConfig.Reader.register(printCommand)
 
-	printCommand.AddTime("from", "f", time.Time{}, &printConfig.From, dateTimeLayout, "start time (UTC)")
-	printCommand.AddTime("until", "u", time.Time{}, &printConfig.Until, dateTimeLayout, "end time (UTC)")
 }
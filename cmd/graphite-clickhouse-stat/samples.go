@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// writeSampleLines write raw log lines of sample and error requests as NDJSON:
+// to directory (path is existing directory or ends with path separator) as file per request key (instance/request id),
+// or to single file
+func writeSampleLines(path string, lines map[string][]string) error {
+	keys := make([]string, 0, len(lines))
+	for key := range lines {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if fi, err := os.Stat(path); (err == nil && fi.IsDir()) || strings.HasSuffix(path, string(os.PathSeparator)) {
+		if err = os.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			name := filepath.Join(path, strings.ReplaceAll(key, string(os.PathSeparator), "_")+".ndjson")
+			if err = writeLines(name, lines[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var all []string
+	for _, key := range keys {
+		all = append(all, lines[key]...)
+	}
+	return writeLines(path, all)
+}
+
+func writeLines(path string, lines []string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, line := range lines {
+		_, _ = w.WriteString(line)
+		_ = w.WriteByte('\n')
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-json"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/aggregate"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/reader"
+)
+
+// aggState is a state for incremental aggregate of growing log: read position with in-flight requests and raw summary
+type aggState struct {
+	Checkpoint *reader.Checkpoint     `json:"checkpoint"`
+	Summary    *aggregate.StatSummary `json:"summary"`
+}
+
+// loadAggState load state file, return empty state if file not exist
+func loadAggState(path string) (*aggState, error) {
+	state := &aggState{}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			state.Summary = aggregate.NewStatSummary()
+			return state, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(b, state); err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	if state.Summary == nil {
+		state.Summary = aggregate.NewStatSummary()
+	}
+	return state, nil
+}
+
+// save write state file atomically (via temporary file rename)
+func (state *aggState) save(path string) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -1,15 +1,12 @@
 package main
 
 import (
-	"bufio"
 	"errors"
-	"io"
-	"os"
 	"strings"
 	"time"
 
-	"github.com/goccy/go-json"
 	"github.com/msaf1980/go-clipper"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/reader"
 	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
 	"github.com/msaf1980/graphite-clickhouse-stat/pkg/top"
 )
@@ -21,18 +18,21 @@ type TopConfig struct {
 	// TODO: increment flag
 	Verbose []bool
 
-	File string
+	Inputs    stringsValue
+	Follow    []bool
+	FromStart []bool
+	Workers   int
+	Reader    ReaderConfig
 
-	From  time.Time
-	Until time.Time
+	InFlight time.Duration
 }
 
 var topConfig TopConfig
 
-func printTop(queries map[string]*stat.Stat, n int, sortKey stat.Sort, from, until int64, cleanup bool) {
+func printTop(queries map[string]*stat.Stat, n int, sortKey stat.Sort, from, until int64, cleanup bool, loc *time.Location, dims []stat.Dimension) {
 	stats := top.GetTop(queries, n, sortKey, from, until, cleanup)
 	for _, s := range stats {
-		printStat(s.Id, s, len(topConfig.Verbose))
+		printStat(s.Id, s, len(topConfig.Verbose), loc, dims)
 	}
 }
 
@@ -46,72 +46,97 @@ func topRun() error {
 
 	var timeStamp time.Time
 
-	var (
-		in    io.ReadCloser
-		err   error
-		from  int64
-		until int64
-	)
-	if topConfig.File == "" {
-		in = os.Stdin
-	} else {
-		if in, err = os.Open(topConfig.File); err != nil {
-			return err
-		}
-		defer in.Close()
-	}
-
 	queries := make(map[string]*stat.Stat)
-	var logEntry map[string]interface{}
 
-	if !topConfig.From.IsZero() {
-		from = topConfig.From.UnixNano()
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	follow := len(topConfig.Follow) > 0
+	cfg, err := topConfig.Reader.config(reader.Config{Workers: topConfig.Workers})
+	if err != nil {
+		return err
 	}
-	if !topConfig.Until.IsZero() {
-		until = topConfig.Until.UnixNano()
+	r, err := openReader(ctx, topConfig.Inputs, topConfig.Reader.Instance, follow, len(topConfig.FromStart) > 0, cfg)
+	if err != nil {
+		return err
 	}
+	defer r.Close()
 
-	scanner := bufio.NewScanner(in)
-	for scanner.Scan() {
-		stat.ResetLogEntry(logEntry)
-		line := scanner.Bytes()
-		err := json.Unmarshal(line, &logEntry)
-		if err == nil {
-			id := stat.LogEntryProcess(logEntry, queries)
-			if id != "" {
-				s := queries[id]
-				print := true
-				if from > 0 && s.TimeStamp < from {
-					delete(queries, id)
-					continue
-				}
-				if print && until > 0 && s.TimeStamp >= until {
-					delete(queries, id)
-					continue
+	loc, dims := topConfig.Reader.loc, cfg.Processor.Dimensions
+	flush := func(now int64) {
+		if len(queries) > 0 {
+			printHeader(len(topConfig.Verbose), loc, dims)
+			printTop(queries, topConfig.Top, topConfig.QuerySort, cfg.From, cfg.Until, true, loc, dims)
+		}
+		if topConfig.InFlight > 0 {
+			// log time in read mode, wall-clock in follow mode
+			if inFlight := r.InFlight(now, int64(topConfig.InFlight)); len(inFlight) > 0 {
+				printOrphans("in-flight requests, pending for "+topConfig.InFlight.String()+" or longer", inFlight, now, len(topConfig.Verbose), loc)
+			}
+		}
+	}
+	add := func(s *stat.Stat) {
+		t := time.Unix(0, s.TimeStamp).Truncate(topConfig.Duration)
+		if timeStamp.IsZero() {
+			timeStamp = t
+		} else if timeStamp != t {
+			// next time round, flush  queries
+			if follow {
+				flush(time.Now().UnixNano())
+			} else {
+				flush(t.UnixNano())
+			}
+			timeStamp = t
+		}
+		queries[s.Key()] = s
+	}
+
+	if follow {
+		// read in background, for flush queries by wall-clock, even if no new lines arrive
+		stats := make(chan *stat.Stat, 1024)
+		go func() {
+			for r.Next(ctx) {
+				stats <- r.Stat()
+			}
+			close(stats)
+		}()
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+	LOOP:
+		for {
+			select {
+			case s, ok := <-stats:
+				if !ok {
+					break LOOP
 				}
-				t := time.Unix(s.TimeStamp/1000000000, s.TimeStamp%100000000).Truncate(topConfig.Duration)
-				if timeStamp.IsZero() {
-					timeStamp = t
-				} else if timeStamp != t {
-					// next time round, flush  queries
-					printHeader(len(topConfig.Verbose))
-					printTop(queries, topConfig.Top, topConfig.QuerySort, from, until, true)
-					timeStamp = t
+				add(s)
+			case now := <-ticker.C:
+				if !timeStamp.IsZero() && now.Truncate(topConfig.Duration).After(timeStamp) {
+					// time round ended
+					flush(now.UnixNano())
+					timeStamp = time.Time{}
 				}
 			}
 		}
+	} else {
+		for r.Next(ctx) {
+			add(r.Stat())
+		}
 	}
 
-	if len(queries) > 0 {
-		printHeader(len(topConfig.Verbose))
-		printTop(queries, topConfig.Top, topConfig.QuerySort, from, until, true)
+	if follow {
+		flush(time.Now().UnixNano())
+	} else {
+		flush(r.Clock())
 	}
 
-	return nil
+	return r.finish()
 }
 
 func registerTopCmd(registry *clipper.Registry) {
-	topCommand, _ := registry.RegisterWithCallback("top", "read from stdin and print top queries stat", topRun)
+	topCommand, _ := registry.RegisterWithCallback("top", "read log and print top queries stat", topRun)
 
 	topCommand.AddMultiFlag("verbose", "v", &topConfig.Verbose, "verbose")
 	topCommand.AddDuration("duration", "d", 10*time.Second, &topConfig.Duration, "flush duration")
@@ -119,8 +144,11 @@ func registerTopCmd(registry *clipper.Registry) {
 	topCommand.AddInt("top", "n", 10, &topConfig.Top, "top queries")
 	topCommand.AddValue("sort", "s", &topConfig.QuerySort, false, "top sort by ("+strings.Join(stat.SortStrings(), " | ")+") ")
 
-	topCommand.AddString("input", "i", "", &topConfig.File, "input log file or stdin")
+	topCommand.AddValue("input", "i", &topConfig.Inputs, true, "input log files, globs or directories, merged by timestamp (repeatable, stdin if not set, gzip, zstd, bzip2 and xz compressed logs are detected)")
+	topCommand.AddMultiFlag("follow", "F", &topConfig.Follow, "follow single log file for new lines (like tail -F, reopen after rename or truncate rotation, flush by wall-clock), existing lines are skipped")
+	topCommand.AddMultiFlag("from-start", "", &topConfig.FromStart, "in follow mode read existing lines from the start of file")
+	topCommand.AddInt("workers", "w", 1, &topConfig.Workers, "parallel log parse workers")
+	topCommand.AddDuration("in-flight", "", 0, &topConfig.InFlight, "also print in-flight (not completed) requests, pending for duration or longer (0 - disabled)")
+	topConfig.Reader.register(topCommand)
 
-	topCommand.AddTime("from", "f", time.Time{}, &topConfig.From, dateTimeLayout, "start time (UTC)")
-	topCommand.AddTime("until", "u", time.Time{}, &topConfig.Until, dateTimeLayout, "end time (UTC)")
 }
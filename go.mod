@@ -5,6 +5,8 @@ go 1.16
 require (
 	github.com/goccy/go-json v0.10.2
 	github.com/google/go-cmp v0.5.9
+	github.com/klauspost/compress v1.15.15
 	github.com/msaf1980/go-clipper v0.0.24
 	github.com/msaf1980/go-stringutils v0.1.5
+	github.com/ulikunitz/xz v0.5.11
 )
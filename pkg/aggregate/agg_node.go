@@ -45,3 +45,13 @@ func (a *AggNode) Calc(values []float64) error {
 
 	return nil
 }
+
+// CalcSamples calc percentiles by samples (approximate, if samples are compacted), min and max are exact
+func (a *AggNode) CalcSamples(s *Samples) error {
+	if err := a.Calc(s.Values); err != nil {
+		return err
+	}
+	a.Min = s.Min
+	a.Max = s.Max
+	return nil
+}
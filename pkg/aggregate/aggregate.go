@@ -32,6 +32,8 @@ type StatIndexAggNode struct {
 	ReadBytes AggNode
 	Times     AggNode
 	// IndexN    AggNode
+
+	ErrorClasses []*ErrorNode `json:",omitempty"` // failed queries by error class
 }
 
 func GreaterIndexAggP99ByTime(a, b *StatIndexAggNode) bool {
@@ -187,6 +189,7 @@ type LabelKey struct {
 	RequestType   string `json:"requestType"`
 	DurationLabel string `json:"durationLabel"`
 	OffsetLabel   string `json:"OffsetLabel"`
+	Group         string `json:"group,omitempty"`
 }
 
 func BuildLabelKey(k StatKey) LabelKey {
@@ -194,6 +197,7 @@ func BuildLabelKey(k StatKey) LabelKey {
 		RequestType:   k.RequestType,
 		DurationLabel: k.DurationLabel,
 		OffsetLabel:   k.OffsetLabel,
+		Group:         k.Group,
 	}
 }
 
@@ -206,6 +210,7 @@ type StatKey struct {
 	Queries       string
 	DurationLabel string
 	OffsetLabel   string
+	Group         string `json:",omitempty"` // group label (see GroupBy)
 }
 
 func (k *StatKey) Empty() bool {
@@ -226,22 +231,26 @@ type StatIndexNode struct {
 
 	SampleId    string
 	maxReadRows int64
+	sampleKey   string // sample request key (see stat.Stat.Key), for sample lines
 
 	ErrorId      string
 	maxErrorTime float64
+	errorKey     string // error request key (see stat.Stat.Key), for sample lines
 
 	N      int64
 	Errors int64
 
-	Metrics []float64
+	Metrics Samples
 
 	IndexCacheHit  int64
 	IndexCacheMiss int64
 
-	ReadRows  []float64
-	ReadBytes []float64
-	Times     []float64
-	IndexN    []float64 // TODO: may be refactor with buckets ?
+	ReadRows  Samples
+	ReadBytes Samples
+	Times     Samples
+	IndexN    Samples // TODO: may be refactor with buckets ?
+
+	ErrorClasses ErrorClasses `json:",omitempty"`
 }
 
 type StatIndexSummary map[StatKey]*StatIndexNode
@@ -254,13 +263,8 @@ func (sSum StatIndexSummary) Append(indexKey StatKey, statIndex []StatQuery, s *
 	sNode, ok := sSum[indexKey]
 	if !ok {
 		sNode = &StatIndexNode{
-			IndexKey:  indexKey,
-			Queries:   statIndex,
-			ReadRows:  make([]float64, 0, 16),
-			ReadBytes: make([]float64, 0, 16),
-			Times:     make([]float64, 0, 16),
-			Metrics:   make([]float64, 0, 16),
-			IndexN:    make([]float64, 0, 16),
+			IndexKey: indexKey,
+			Queries:  statIndex,
 		}
 		sSum[indexKey] = sNode
 	}
@@ -277,6 +281,7 @@ func (sSum StatIndexSummary) Append(indexKey StatKey, statIndex []StatQuery, s *
 			sNode.IndexCacheMiss++
 		case stat.StatusError:
 			errs++
+			sNode.ErrorClasses.Append("index", q.Error, s.Id)
 		case stat.StatusCached:
 			// cached++
 			sNode.IndexCacheHit++
@@ -284,28 +289,39 @@ func (sSum StatIndexSummary) Append(indexKey StatKey, statIndex []StatQuery, s *
 	}
 	sNode.N++
 	if errs == 0 {
-		sNode.ReadRows = append(sNode.ReadRows, float64(s.IndexReadRows))
-		sNode.ReadBytes = append(sNode.ReadBytes, float64(s.IndexReadBytes))
-		sNode.Metrics = append(sNode.Metrics, float64(s.Metrics))
+		sNode.ReadRows.Append(float64(s.IndexReadRows))
+		sNode.ReadBytes.Append(float64(s.IndexReadBytes))
+		sNode.Metrics.Append(float64(s.Metrics))
 	} else {
 		sNode.Errors++
 		sNode.ErrorId = s.Id
+		sNode.errorKey = s.Key()
 		if sNode.maxErrorTime < s.QueryTime {
 			sNode.maxErrorTime = s.QueryTime
 		}
 	}
-	sNode.Times = append(sNode.Times, times)
+	sNode.Times.Append(times)
 
 	// sNode.ErrorsPcnt = append(sNode.ErrorsPcnt, float64(errs)/float64(len(s.Index))*100)
-	sNode.IndexN = append(sNode.IndexN, float64(len(s.Index)))
+	sNode.IndexN.Append(float64(len(s.Index)))
 
 	if sNode.maxReadRows < s.IndexReadRows {
 		sNode.maxReadRows = s.IndexReadRows
 		sNode.SampleId = s.Id
+		sNode.sampleKey = s.Key()
 	}
 	return sNode
 }
 
+// Compact reduce kept values of nodes to max (see Samples.Compact)
+func (sSum StatIndexSummary) Compact(max int) {
+	for _, n := range sSum {
+		for _, samples := range []*Samples{&n.Metrics, &n.ReadRows, &n.ReadBytes, &n.Times, &n.IndexN} {
+			samples.Compact(max)
+		}
+	}
+}
+
 func (sSum StatIndexSummary) Aggregate() map[LabelKey][]*StatIndexAggNode {
 	// aggStat := make([]*StatIndexAggNode, len(sSum))
 	aggStats := make(map[LabelKey][]*StatIndexAggNode)
@@ -325,16 +341,18 @@ func (sSum StatIndexSummary) Aggregate() map[LabelKey][]*StatIndexAggNode {
 		aggStat.N = statNode.N
 		aggStat.ErrorsPcnt = float64(statNode.Errors) / float64(statNode.N) * 100
 
-		_ = aggStat.Metrics.Calc(statNode.Metrics)
+		_ = aggStat.Metrics.CalcSamples(&statNode.Metrics)
 
 		IndexCache := statNode.IndexCacheMiss + statNode.IndexCacheHit
 		if IndexCache > 0 {
 			aggStat.IndexCacheHitPcnt = float64(statNode.IndexCacheHit) / float64(IndexCache) * 100
 		}
 
-		_ = aggStat.ReadRows.Calc(statNode.ReadRows)
-		_ = aggStat.ReadBytes.Calc(statNode.ReadBytes)
-		_ = aggStat.Times.Calc(statNode.Times)
+		_ = aggStat.ReadRows.CalcSamples(&statNode.ReadRows)
+		_ = aggStat.ReadBytes.CalcSamples(&statNode.ReadBytes)
+		_ = aggStat.Times.CalcSamples(&statNode.Times)
+
+		aggStat.ErrorClasses = statNode.ErrorClasses.Aggregate()
 
 		// aggStat.IndexN.Calc(statNode.IndexN)
 
@@ -373,12 +391,15 @@ type StatRequestAggNode struct {
 	DataReadRows  AggNode
 	DataReadBytes AggNode
 	DataTimes     AggNode
+	ParseTimes    AggNode // data parse runtime (graphite-clickhouse CPU time)
 
 	// DataN AggNode
 
 	IndexReadRows  AggNode
 	IndexReadBytes AggNode
 	IndexTimes     AggNode
+
+	ErrorClasses []*ErrorNode `json:",omitempty"` // failed index and data queries by error class
 }
 
 func LessDataAggP99ByRows(a, b *StatRequestAggNode) bool {
@@ -431,9 +452,11 @@ type StatQueryNode struct {
 
 	SampleId    string
 	maxReadRows int64
+	sampleKey   string // sample request key (see stat.Stat.Key), for sample lines
 
 	ErrorId      string
 	maxErrorTime float64
+	errorKey     string // error request key (see stat.Stat.Key), for sample lines
 
 	N           int64
 	Errors      int64
@@ -444,23 +467,26 @@ type StatQueryNode struct {
 	IndexCacheMiss int64
 
 	RequestStatus map[int64]int64
-	RequestTimes  []float64
-	QueryTimes    []float64
+	RequestTimes  Samples
+	QueryTimes    Samples
 
-	Metrics []float64
-	Points  []float64
-	Bytes   []float64
+	Metrics Samples
+	Points  Samples
+	Bytes   Samples
 
-	ReadRows  []float64
-	ReadBytes []float64
+	ReadRows  Samples
+	ReadBytes Samples
 
-	DataReadRows  []float64
-	DataReadBytes []float64
-	DataTimes     []float64
+	DataReadRows  Samples
+	DataReadBytes Samples
+	DataTimes     Samples
+	ParseTimes    Samples
 
-	IndexReadRows  []float64
-	IndexReadBytes []float64
-	IndexTimes     []float64
+	IndexReadRows  Samples
+	IndexReadBytes Samples
+	IndexTimes     Samples
+
+	ErrorClasses ErrorClasses `json:",omitempty"`
 
 	// DataErrorsPcnt []float64
 	// DataN          []float64
@@ -476,22 +502,10 @@ func (sSum StatRequestSummary) Append(indexKey, dataKey StatKey, statQueries []S
 	sNode, ok := sSum[dataKey]
 	if !ok {
 		sNode = &StatQueryNode{
-			IndexKey:       indexKey,
-			DataKey:        dataKey,
-			Queries:        statQueries,
-			RequestStatus:  make(map[int64]int64),
-			RequestTimes:   make([]float64, 0, 16),
-			QueryTimes:     make([]float64, 0, 16),
-			ReadRows:       make([]float64, 0, 16),
-			ReadBytes:      make([]float64, 0, 16),
-			DataReadBytes:  make([]float64, 0, 16),
-			DataReadRows:   make([]float64, 0, 16),
-			DataTimes:      make([]float64, 0, 16),
-			IndexReadBytes: make([]float64, 0, 16),
-			IndexReadRows:  make([]float64, 0, 16),
-			IndexTimes:     make([]float64, 0, 16),
-			Metrics:        make([]float64, 0, 16),
-			Points:         make([]float64, 0, 16),
+			IndexKey:      indexKey,
+			DataKey:       dataKey,
+			Queries:       statQueries,
+			RequestStatus: make(map[int64]int64),
 			// DataErrorsPcnt: make([]float64, 0, 16),
 			// DataN:          make([]float64, 0, 16),
 		}
@@ -505,17 +519,19 @@ func (sSum StatRequestSummary) Append(indexKey, dataKey StatKey, statQueries []S
 	sNode.N++
 	sNode.RequestStatus[s.RequestStatus]++
 	if s.RequestStatus == http.StatusOK || s.RequestStatus == http.StatusNotFound {
-		sNode.ReadRows = append(sNode.ReadRows, float64(s.ReadRows))
-		sNode.ReadBytes = append(sNode.ReadBytes, float64(s.ReadBytes))
+		sNode.ReadRows.Append(float64(s.ReadRows))
+		sNode.ReadBytes.Append(float64(s.ReadBytes))
 	} else {
 		sNode.Errors++
 		if sNode.maxErrorTime < s.QueryTime {
 			sNode.maxErrorTime = s.QueryTime
 			sNode.ErrorId = s.Id
+			sNode.errorKey = s.Key()
+			sNode.errorKey = s.Key()
 		}
 	}
-	sNode.RequestTimes = append(sNode.RequestTimes, s.RequestTime)
-	sNode.QueryTimes = append(sNode.QueryTimes, s.QueryTime)
+	sNode.RequestTimes.Append(s.RequestTime)
+	sNode.QueryTimes.Append(s.QueryTime)
 
 	if len(s.Index) > 0 {
 		for _, idx := range s.Index {
@@ -527,15 +543,16 @@ func (sSum StatRequestSummary) Append(indexKey, dataKey StatKey, statQueries []S
 				sNode.IndexCacheHit++
 			case stat.StatusError:
 				indexErrs++
+				sNode.ErrorClasses.Append("index", idx.Error, s.Id)
 			}
 		}
 
-		sNode.IndexTimes = append(sNode.IndexTimes, indexTimes)
+		sNode.IndexTimes.Append(indexTimes)
 
 		if indexErrs == 0 {
-			sNode.Metrics = append(sNode.Metrics, float64(s.Metrics))
-			sNode.IndexReadRows = append(sNode.IndexReadRows, float64(s.IndexReadRows))
-			sNode.IndexReadBytes = append(sNode.IndexReadBytes, float64(s.IndexReadBytes))
+			sNode.Metrics.Append(float64(s.Metrics))
+			sNode.IndexReadRows.Append(float64(s.IndexReadRows))
+			sNode.IndexReadBytes.Append(float64(s.IndexReadBytes))
 		} else {
 			sNode.IndexErrors++
 		}
@@ -547,18 +564,20 @@ func (sSum StatRequestSummary) Append(indexKey, dataKey StatKey, statQueries []S
 			switch q.Status {
 			case stat.StatusError:
 				dataErrs++
+				sNode.ErrorClasses.Append("data", q.Error, s.Id)
 			}
 		}
 
-		sNode.DataTimes = append(sNode.DataTimes, dataTimes)
+		sNode.DataTimes.Append(dataTimes)
 
 		// sNode.DataErrorsPcnt = append(sNode.DataErrorsPcnt, float64(dataErrs)/float64(len(s.Data))*100)
 		// sNode.DataN = append(sNode.DataN, float64(len(s.Data)))
 
 		if dataErrs == 0 {
-			sNode.Points = append(sNode.Points, float64(s.Points))
-			sNode.DataReadRows = append(sNode.DataReadRows, float64(s.DataReadRows))
-			sNode.DataReadBytes = append(sNode.DataReadBytes, float64(s.DataReadBytes))
+			sNode.Points.Append(float64(s.Points))
+			sNode.DataReadRows.Append(float64(s.DataReadRows))
+			sNode.DataReadBytes.Append(float64(s.DataReadBytes))
+			sNode.ParseTimes.Append(s.DataParseTime)
 		} else {
 			sNode.DataErrors++
 		}
@@ -567,11 +586,24 @@ func (sSum StatRequestSummary) Append(indexKey, dataKey StatKey, statQueries []S
 	if sNode.maxReadRows < s.ReadRows {
 		sNode.maxReadRows = s.ReadRows
 		sNode.SampleId = s.Id
+		sNode.sampleKey = s.Key()
 	}
 
 	return sNode
 }
 
+// Compact reduce kept values of nodes to max (see Samples.Compact)
+func (sSum StatRequestSummary) Compact(max int) {
+	for _, n := range sSum {
+		for _, samples := range []*Samples{
+			&n.RequestTimes, &n.QueryTimes, &n.Metrics, &n.Points, &n.Bytes, &n.ReadRows, &n.ReadBytes,
+			&n.DataReadRows, &n.DataReadBytes, &n.DataTimes, &n.ParseTimes, &n.IndexReadRows, &n.IndexReadBytes, &n.IndexTimes,
+		} {
+			samples.Compact(max)
+		}
+	}
+}
+
 func (sSum StatRequestSummary) Aggregate() map[LabelKey][]*StatRequestAggNode {
 	aggStats := make(map[LabelKey][]*StatRequestAggNode)
 
@@ -599,18 +631,21 @@ func (sSum StatRequestSummary) Aggregate() map[LabelKey][]*StatRequestAggNode {
 		aggStat.SampleId = statNode.SampleId
 		aggStat.ErrorId = statNode.ErrorId
 
-		_ = aggStat.Metrics.Calc(statNode.Metrics)
-		_ = aggStat.Points.Calc(statNode.Points)
-		_ = aggStat.Bytes.Calc(statNode.Bytes)
+		_ = aggStat.Metrics.CalcSamples(&statNode.Metrics)
+		_ = aggStat.Points.CalcSamples(&statNode.Points)
+		_ = aggStat.Bytes.CalcSamples(&statNode.Bytes)
 
-		_ = aggStat.ReadRows.Calc(statNode.ReadRows)
-		_ = aggStat.ReadBytes.Calc(statNode.ReadBytes)
-		_ = aggStat.RequestTimes.Calc(statNode.RequestTimes)
-		_ = aggStat.QueryTimes.Calc(statNode.QueryTimes)
+		_ = aggStat.ReadRows.CalcSamples(&statNode.ReadRows)
+		_ = aggStat.ReadBytes.CalcSamples(&statNode.ReadBytes)
+		_ = aggStat.RequestTimes.CalcSamples(&statNode.RequestTimes)
+		_ = aggStat.QueryTimes.CalcSamples(&statNode.QueryTimes)
 
-		_ = aggStat.DataReadRows.Calc(statNode.DataReadRows)
-		_ = aggStat.DataReadBytes.Calc(statNode.DataReadBytes)
-		_ = aggStat.DataTimes.Calc(statNode.DataTimes)
+		_ = aggStat.DataReadRows.CalcSamples(&statNode.DataReadRows)
+		_ = aggStat.DataReadBytes.CalcSamples(&statNode.DataReadBytes)
+		_ = aggStat.DataTimes.CalcSamples(&statNode.DataTimes)
+		_ = aggStat.ParseTimes.CalcSamples(&statNode.ParseTimes)
+
+		aggStat.ErrorClasses = statNode.ErrorClasses.Aggregate()
 
 		aggStats[label] = append(aggStats[label], aggStat)
 	}
@@ -618,7 +653,7 @@ func (sSum StatRequestSummary) Aggregate() map[LabelKey][]*StatRequestAggNode {
 	return aggStats
 }
 
-func BuildStatKey(s *stat.Stat) (indexKey, queryKey *StatKey, statIndex, statQueries []StatQuery) {
+func BuildStatKey(s *stat.Stat, groupBy GroupBy) (indexKey, queryKey *StatKey, statIndex, statQueries []StatQuery) {
 	var (
 		sbIndex, sbQuery stringutils.Builder
 		maxDays          int
@@ -633,9 +668,10 @@ func BuildStatKey(s *stat.Stat) (indexKey, queryKey *StatKey, statIndex, statQue
 
 	sbIndex.Grow(128)
 	_ = sbIndex.WriteByte('[')
-	indexKey = &StatKey{RequestType: s.RequestType}
+	group := groupBy.Label(s)
+	indexKey = &StatKey{RequestType: s.RequestType, Group: group}
 
-	queryKey = &StatKey{RequestType: s.RequestType}
+	queryKey = &StatKey{RequestType: s.RequestType, Group: group}
 	sbQuery.Grow(128)
 	_ = sbQuery.WriteByte('[')
 
@@ -719,18 +755,24 @@ func BuildStatKey(s *stat.Stat) (indexKey, queryKey *StatKey, statIndex, statQue
 type StatAggSumSlice struct {
 	Index    []*StatIndexAggNode
 	Requests []*StatRequestAggNode
+	TagTerms []*TagTermNode `json:",omitempty"`
 }
 
 type StatAggSum struct {
 	Index map[LabelKey][]*StatIndexAggNode
 	// DataIndex map[StatKey]*StatIndexAggNode
 	Requests map[LabelKey][]*StatRequestAggNode
+	// tagged index queries stat by tag term, sorted by read rows
+	TagTerms []*TagTermNode
 }
 
 func LabelsSort(keys []LabelKey) {
 	sort.Slice(keys, func(i, j int) bool {
 		if keys[i].RequestType == keys[j].RequestType {
 			if keys[i].DurationLabel == keys[j].DurationLabel {
+				if keys[i].OffsetLabel == keys[j].OffsetLabel {
+					return keys[i].Group < keys[j].Group
+				}
 				return keys[i].OffsetLabel < keys[j].OffsetLabel
 			}
 			return keys[i].DurationLabel < keys[j].DurationLabel
@@ -762,6 +804,7 @@ func (aSum *StatAggSum) Slice() StatAggSumSlice {
 	agg := StatAggSumSlice{
 		Index:    make([]*StatIndexAggNode, 0, len(aSum.Index)*2),
 		Requests: make([]*StatRequestAggNode, 0, len(aSum.Requests)*2),
+		TagTerms: aSum.TagTerms,
 	}
 	for _, s := range aSum.Index {
 		agg.Index = append(agg.Index, s...)
@@ -784,6 +827,17 @@ type StatSummary struct {
 	Index StatIndexSummary
 	// DataIndex StatIndexSummary
 	Requests StatRequestSummary
+	// tagged index queries by tag term (not splitted by groups)
+	TagTerms TagTermSummary
+
+	// split stat to groups by dimensions
+	GroupBy GroupBy
+	// split render requests per target (tag terms stat is not splitted)
+	SplitTargets bool
+
+	// raw log lines of sample and error requests by request key (for requests with lines), with groups references count
+	lines     map[string][]string
+	linesRefs map[string]int
 }
 
 func NewStatSummary() *StatSummary {
@@ -791,11 +845,30 @@ func NewStatSummary() *StatSummary {
 		Index: NewStatIndexSummary(),
 		// DataIndex: NewStatIndexSummary(),
 		Requests: NewStatQuerySummary(),
+		TagTerms: NewTagTermSummary(),
 	}
 }
 
 func (sSum *StatSummary) Append(s *stat.Stat) {
-	indexKey, dataKey, statIndex, statQueries := BuildStatKey(s)
+	sSum.TagTerms.Append(s)
+	if sSum.SplitTargets && s.RequestType == "render" {
+		// per-target stat, request-level stat is kept in tag terms
+		for _, t := range s.SplitTargets() {
+			sSum.append(t)
+		}
+		return
+	}
+	sSum.append(s)
+}
+
+// Compact reduce kept values for percentiles to max per node field (for bounded state), percentiles are approximate after it
+func (sSum *StatSummary) Compact(max int) {
+	sSum.Index.Compact(max)
+	sSum.Requests.Compact(max)
+}
+
+func (sSum *StatSummary) append(s *stat.Stat) {
+	indexKey, dataKey, statIndex, statQueries := BuildStatKey(s, sSum.GroupBy)
 
 	// idx := sSum.Index.Append(*indexKey, statIndex, s)
 	// if dataKey != nil {
@@ -805,14 +878,62 @@ func (sSum *StatSummary) Append(s *stat.Stat) {
 	// sSum.Queries.Append(*dataKey, statQueries, s)
 	// }
 
-	sSum.Index.Append(*indexKey, statIndex, s)
-	sSum.Requests.Append(*indexKey, *dataKey, statQueries, s)
+	if len(s.Lines) == 0 {
+		sSum.Index.Append(*indexKey, statIndex, s)
+		sSum.Requests.Append(*indexKey, *dataKey, statQueries, s)
+		return
+	}
+
+	// sample and error request keys of index and requests groups, lines are kept while request is referenced
+	var prev [4]string
+	if node, ok := sSum.Index[*indexKey]; ok {
+		prev[0], prev[1] = node.sampleKey, node.errorKey
+	}
+	if node, ok := sSum.Requests[*dataKey]; ok {
+		prev[2], prev[3] = node.sampleKey, node.errorKey
+	}
+	idx := sSum.Index.Append(*indexKey, statIndex, s)
+	req := sSum.Requests.Append(*indexKey, *dataKey, statQueries, s)
+	next := [4]string{idx.sampleKey, idx.errorKey, req.sampleKey, req.errorKey}
+	for i := range next {
+		if next[i] != prev[i] {
+			sSum.releaseLines(prev[i])
+			sSum.retainLines(next[i], s.Lines)
+		}
+	}
+}
+
+func (sSum *StatSummary) retainLines(key string, lines []string) {
+	if sSum.lines == nil {
+		sSum.lines = make(map[string][]string)
+		sSum.linesRefs = make(map[string]int)
+	}
+	if sSum.linesRefs[key] == 0 {
+		sSum.lines[key] = lines
+	}
+	sSum.linesRefs[key]++
+}
+
+func (sSum *StatSummary) releaseLines(key string) {
+	if n := sSum.linesRefs[key]; n > 1 {
+		sSum.linesRefs[key] = n - 1
+	} else if n == 1 {
+		delete(sSum.linesRefs, key)
+		delete(sSum.lines, key)
+	}
+}
+
+// SampleLines return raw log lines of sample and error requests by request key (see stat.Stat.Key).
+// Lines are kept only for requests, read with lines (and not restored from state).
+func (sSum *StatSummary) SampleLines() map[string][]string {
+	return sSum.lines
 }
 
 func (sSum *StatSummary) Aggregate() *StatAggSum {
 	statAggSum := &StatAggSum{}
 	statAggSum.Index = sSum.Index.Aggregate()
 	statAggSum.Requests = sSum.Requests.Aggregate()
+	statAggSum.TagTerms = sSum.TagTerms.Aggregate()
 	// for _, labels := range statAggSum.Index {
 	// 	for _, idx := range labels {
 	// 		if !idx.DataKey.Empty() {
@@ -111,6 +111,9 @@ func Test_StatSummary_append(t *testing.T) {
 					ReadRows:  AggNode{Min: 0, Max: 414, P50: 0, P90: 207, P95: 207, P99: 207},
 					ReadBytes: AggNode{Min: 0, Max: 14168, P50: 0, P90: 7084, P95: 7084, P99: 7084},
 					Times:     AggNode{Min: 0, Max: 10, P50: 0, P90: 5.5, P95: 5.5, P99: 5.5},
+					ErrorClasses: []*ErrorNode{
+						{ErrorKey: ErrorKey{Query: "index"}, N: 1, SampleId: "1f72e822bed05bebd97a9bdcc4654f1d"},
+					},
 				},
 			},
 		},
@@ -152,6 +155,10 @@ func Test_StatSummary_append(t *testing.T) {
 					DataReadRows:  AggNode{Min: 12284, Max: 12284, P50: 12284, P90: 12284, P95: 12284, P99: 12284},
 					DataReadBytes: AggNode{Min: 16497094, Max: 16497094, P50: 16497094, P90: 16497094, P95: 16497094, P99: 16497094},
 					DataTimes:     AggNode{Min: 2, Max: 10, P50: 2, P90: 6, P95: 6, P99: 6},
+					ErrorClasses: []*ErrorNode{
+						{ErrorKey: ErrorKey{Query: "data"}, N: 1, SampleId: "1f72e822bed05bebd97a9bdcc4654f1c"},
+						{ErrorKey: ErrorKey{Query: "index"}, N: 1, SampleId: "1f72e822bed05bebd97a9bdcc4654f1d"},
+					},
 				},
 			},
 		},
@@ -246,3 +253,99 @@ func Test_StatSummary_append(t *testing.T) {
 	// 	}
 	// }
 }
+
+func Test_StatSummary_SampleLines(t *testing.T) {
+	newStat := func(instance, id string, status int64, indexStatus stat.Status, readRows int64) *stat.Stat {
+		return &stat.Stat{
+			RequestType: "render", Instance: instance, Id: id,
+			TimeStamp:     1674288343773000000,
+			RequestStatus: status, RequestTime: 1, QueryTime: 1,
+			ReadRows: readRows, IndexReadRows: readRows,
+			Queries: []stat.Query{{Query: "test.a", Days: 1, From: 1674288343 - 60, Until: 1674288343}},
+			Index:   []stat.IndexStat{{Status: indexStatus, ReadRows: readRows, Days: 1}},
+		}
+	}
+	statSum := NewStatSummary()
+	stats := []*stat.Stat{
+		newStat("host1", "1", 200, stat.StatusSuccess, 10),
+		// the same request id on other instance is a different request
+		newStat("host2", "1", 200, stat.StatusSuccess, 100),
+		// errors with the same index key, so previous error request lines must be released
+		newStat("host1", "3", 504, stat.StatusError, 0),
+		newStat("host1", "4", 504, stat.StatusError, 0),
+		newStat("host1", "5", 504, stat.StatusError, 0),
+	}
+	line := func(s *stat.Stat) []string {
+		return []string{`{"instance":"` + s.Instance + `","request_id":"` + s.Id + `"}`}
+	}
+	for _, s := range stats {
+		c := *s
+		c.Lines = line(s)
+		statSum.Append(&c)
+	}
+
+	// index and request sample is host2/1, index error is last failed request, request error is first slowest failed request
+	want := map[string][]string{
+		"host2/1": line(stats[1]),
+		"host1/3": line(stats[2]),
+		"host1/5": line(stats[4]),
+	}
+	if got := statSum.SampleLines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("StatSummary.SampleLines() = %s", cmp.Diff(want, got))
+	}
+}
+
+func Test_StatSummary_splitTargets(t *testing.T) {
+	newStat := func(id string, targets ...string) *stat.Stat {
+		s := &stat.Stat{
+			RequestType: "render", Id: id,
+			TimeStamp:     1674288343773000000,
+			RequestStatus: 200, RequestTime: 1, QueryTime: 1,
+			WaitStatus: stat.StatusSuccess,
+		}
+		for i, target := range targets {
+			s.Queries = append(s.Queries, stat.Query{Query: target, Days: 1, From: 1674288343 - 60, Until: 1674288343, Metrics: 1})
+			s.Index = append(s.Index, stat.IndexStat{Status: stat.StatusCached, Days: 1, Targets: []int{i}})
+			s.Metrics++
+		}
+		return s
+	}
+	stats := []*stat.Stat{newStat("1", "test.a", "test.b"), newStat("2", "test.a", "test.c")}
+
+	tests := []struct {
+		splitTargets bool
+		want         map[string]int64
+	}{
+		{
+			splitTargets: false,
+			want: map[string]int64{
+				"[{query='test.a',render=10m}{query='test.b',render=10m}]": 1,
+				"[{query='test.a',render=10m}{query='test.c',render=10m}]": 1,
+			},
+		},
+		{
+			splitTargets: true,
+			want: map[string]int64{
+				"[{query='test.a',render=10m}]": 2,
+				"[{query='test.b',render=10m}]": 1,
+				"[{query='test.c',render=10m}]": 1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		statSum := NewStatSummary()
+		statSum.SplitTargets = tt.splitTargets
+		for _, s := range stats {
+			statSum.Append(s)
+		}
+		got := make(map[string]int64)
+		for _, reqs := range statSum.Aggregate().Requests {
+			for _, req := range reqs {
+				got[req.DataKey.Queries] = req.N
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("split targets %v requests = %v, want %v", tt.splitTargets, got, tt.want)
+		}
+	}
+}
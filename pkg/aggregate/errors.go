@@ -0,0 +1,62 @@
+package aggregate
+
+import (
+	"sort"
+)
+
+// ErrorKey is a query errors breakdown key
+type ErrorKey struct {
+	Query string // index or data
+	Class string // error class (see stat.Processor.ErrorClass)
+}
+
+// ErrorNode is a failed queries stat by error class
+type ErrorNode struct {
+	ErrorKey
+
+	N        int64  // failed queries
+	SampleId string // last failed request id
+}
+
+// ErrorClasses is a failed queries stat of summary node by error class (error classes are few, so slice is used)
+type ErrorClasses []*ErrorNode
+
+// Append add failed query to errors stat
+func (errs *ErrorClasses) Append(query, class, id string) {
+	key := ErrorKey{Query: query, Class: class}
+	for _, node := range *errs {
+		if node.ErrorKey == key {
+			node.N++
+			node.SampleId = id
+			return
+		}
+	}
+	*errs = append(*errs, &ErrorNode{ErrorKey: key, N: 1, SampleId: id})
+}
+
+// Aggregate return errors stat copy, sorted by failed queries count
+func (errs ErrorClasses) Aggregate() []*ErrorNode {
+	if len(errs) == 0 {
+		return nil
+	}
+	nodes := make([]*ErrorNode, len(errs))
+	for i, node := range errs {
+		n := *node
+		nodes[i] = &n
+	}
+	SortErrors(nodes)
+	return nodes
+}
+
+// SortErrors sort errors by failed queries count (most frequent first)
+func SortErrors(nodes []*ErrorNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].N == nodes[j].N {
+			if nodes[i].Query == nodes[j].Query {
+				return nodes[i].Class < nodes[j].Class
+			}
+			return nodes[i].Query < nodes[j].Query
+		}
+		return nodes[i].N > nodes[j].N
+	})
+}
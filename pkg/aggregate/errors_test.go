@@ -0,0 +1,74 @@
+package aggregate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+func Test_ErrorClasses(t *testing.T) {
+	statSum := NewStatSummary()
+	statSum.GroupBy = GroupBy{"instance"}
+	stats := []*stat.Stat{
+		{
+			Id: "1", Instance: "host1", RequestType: "render", RequestStatus: 500,
+			Index: []stat.IndexStat{{Status: stat.StatusSuccess}},
+			Data:  []stat.DataStat{{Status: stat.StatusError, Error: "Code: 241 MEMORY_LIMIT_EXCEEDED"}},
+		},
+		{
+			Id: "2", Instance: "host1", RequestType: "render", RequestStatus: 500,
+			Data: []stat.DataStat{{Status: stat.StatusError, Error: "Code: 241 MEMORY_LIMIT_EXCEEDED"}},
+		},
+		{
+			Id: "3", Instance: "host1", RequestType: "render", RequestStatus: 500,
+			Index: []stat.IndexStat{{Status: stat.StatusError, Error: "Code: 159 TIMEOUT_EXCEEDED"}},
+		},
+		{
+			Id: "4", Instance: "host2", RequestType: "render", RequestStatus: 500,
+			Index: []stat.IndexStat{{Status: stat.StatusError, Error: "connection refused"}},
+		},
+	}
+	for _, s := range stats {
+		statSum.Append(s)
+	}
+	aggSum := statSum.Aggregate()
+
+	requestErrors := make(map[string][]*ErrorNode)
+	for label, nodes := range aggSum.Requests {
+		for _, node := range nodes {
+			requestErrors[label.Group] = append(requestErrors[label.Group], node.ErrorClasses...)
+		}
+	}
+	wantRequestErrors := map[string][]*ErrorNode{
+		"instance=host1": {
+			{ErrorKey: ErrorKey{Query: "data", Class: "Code: 241 MEMORY_LIMIT_EXCEEDED"}, N: 2, SampleId: "2"},
+			{ErrorKey: ErrorKey{Query: "index", Class: "Code: 159 TIMEOUT_EXCEEDED"}, N: 1, SampleId: "3"},
+		},
+		"instance=host2": {
+			{ErrorKey: ErrorKey{Query: "index", Class: "connection refused"}, N: 1, SampleId: "4"},
+		},
+	}
+	if !reflect.DeepEqual(requestErrors, wantRequestErrors) {
+		t.Errorf("StatSummary.Aggregate().Requests errors %s", cmp.Diff(wantRequestErrors, requestErrors))
+	}
+
+	indexErrors := make(map[string][]*ErrorNode)
+	for label, nodes := range aggSum.Index {
+		for _, node := range nodes {
+			indexErrors[label.Group] = append(indexErrors[label.Group], node.ErrorClasses...)
+		}
+	}
+	wantIndexErrors := map[string][]*ErrorNode{
+		"instance=host1": {
+			{ErrorKey: ErrorKey{Query: "index", Class: "Code: 159 TIMEOUT_EXCEEDED"}, N: 1, SampleId: "3"},
+		},
+		"instance=host2": {
+			{ErrorKey: ErrorKey{Query: "index", Class: "connection refused"}, N: 1, SampleId: "4"},
+		},
+	}
+	if !reflect.DeepEqual(indexErrors, wantIndexErrors) {
+		t.Errorf("StatSummary.Aggregate().Index errors %s", cmp.Diff(wantIndexErrors, indexErrors))
+	}
+}
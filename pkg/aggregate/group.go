@@ -0,0 +1,89 @@
+package aggregate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+// GroupBy is a stat dimensions list for split aggregated stat to groups (like per instance)
+type GroupBy []string
+
+// GroupByStrings return builtin dimensions names (custom dimensions are set with stat.DimensionPrefix)
+func GroupByStrings() []string {
+	return stat.DimensionStrings()
+}
+
+// Set add comma-separated dimensions
+func (g *GroupBy) Set(value string, _ bool) error {
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !validGroupBy(name) {
+			return fmt.Errorf("invalid group by %s", name)
+		}
+		*g = append(*g, name)
+	}
+	return nil
+}
+
+func (g *GroupBy) String() string {
+	return strings.Join(*g, ",")
+}
+
+func (g *GroupBy) Type() string {
+	return "group_by"
+}
+
+func (g *GroupBy) Reset(i interface{}) {
+	*g = i.(GroupBy)
+}
+
+func (g *GroupBy) Get() interface{} {
+	return *g
+}
+
+func validGroupBy(name string) bool {
+	if len(name) > len(stat.DimensionPrefix) && strings.HasPrefix(name, stat.DimensionPrefix) {
+		return true
+	}
+	for _, s := range stat.DimensionStrings() {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Label return group label of stat, like instance=host1
+func (g GroupBy) Label(s *stat.Stat) string {
+	if len(g) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for i, name := range g {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteByte('=')
+		sb.WriteString(s.Dimension(name))
+	}
+	return sb.String()
+}
+
+// Equal compare dimensions lists
+func (g GroupBy) Equal(other GroupBy) bool {
+	if len(g) != len(other) {
+		return false
+	}
+	for i := range g {
+		if g[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
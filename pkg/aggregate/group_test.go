@@ -0,0 +1,75 @@
+package aggregate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+func TestGroupBy_Set(t *testing.T) {
+	var g GroupBy
+	if err := g.Set("instance", false); err != nil {
+		t.Fatalf("GroupBy.Set() error = %v", err)
+	}
+	if !reflect.DeepEqual(g, GroupBy{"instance"}) {
+		t.Errorf("GroupBy = %v", g)
+	}
+	if err := g.Set("instance,unknown", false); err == nil {
+		t.Error("GroupBy.Set() must fail for unknown dimension")
+	}
+}
+
+func Test_StatSummary_groupBy(t *testing.T) {
+	statSum := NewStatSummary()
+	statSum.GroupBy = GroupBy{"instance"}
+	stats := []*stat.Stat{
+		{Id: "1", RequestType: "render", RequestStatus: 200, Queries: []stat.Query{{Query: "test.a", Days: 1}}},
+		{Id: "2", RequestType: "render", RequestStatus: 200, Queries: []stat.Query{{Query: "test.a", Days: 1}}},
+		{Id: "3", RequestType: "render", RequestStatus: 504, Queries: []stat.Query{{Query: "test.a", Days: 1}}},
+	}
+	for i, s := range stats {
+		s := *s
+		if i%2 == 0 {
+			s.Instance = "host1"
+		} else {
+			s.Instance = "host2"
+		}
+		statSum.Append(&s)
+	}
+	aggSum := statSum.Aggregate()
+
+	groups := make(map[string]int64)
+	for label, idxs := range aggSum.Requests {
+		for _, idx := range idxs {
+			if idx.DataKey.Group != label.Group {
+				t.Errorf("request group %q in label %q", idx.DataKey.Group, label.Group)
+			}
+			groups[label.Group] += idx.N
+		}
+	}
+	want := map[string]int64{"instance=host1": 2, "instance=host2": 1}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("requests by group = %v, want %v", groups, want)
+	}
+
+	// without group by
+	statSum = NewStatSummary()
+	statSum.Append(&stat.Stat{Id: "1", Instance: "host1", RequestType: "render"})
+	for label := range statSum.Aggregate().Requests {
+		if label.Group != "" {
+			t.Errorf("label group = %q, want empty", label.Group)
+		}
+	}
+}
+
+func TestGroupBy_Label(t *testing.T) {
+	var g GroupBy
+	if err := g.Set("grafana_org_id,dashboard_id,panel_id", false); err != nil {
+		t.Fatalf("GroupBy.Set() error = %v", err)
+	}
+	s := &stat.Stat{Id: "1", GrafanaOrgId: "1", DashboardId: "12", PanelId: "3"}
+	if got, want := g.Label(s), "grafana_org_id=1,dashboard_id=12,panel_id=3"; got != want {
+		t.Errorf("GroupBy.Label() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package aggregate
+
+import (
+	"encoding/json"
+)
+
+// MaxStateSamples is a maximum of values, kept in summary node of state file for percentiles calculation
+const MaxStateSamples = 1024
+
+// Samples is a values for percentiles calculation with exact count, min and max.
+// All values are kept, until samples are compacted (see Compact), after it every Stride value is kept.
+type Samples struct {
+	Values []float64
+	N      int64 // appended values count
+	Min    float64
+	Max    float64
+	Stride int64 `json:",omitempty"` // kept values stride (0 - all values are kept)
+}
+
+func (s *Samples) Append(v float64) {
+	if s.N == 0 || v < s.Min {
+		s.Min = v
+	}
+	if s.N == 0 || v > s.Max {
+		s.Max = v
+	}
+	if s.Stride <= 1 || s.N%s.Stride == 0 {
+		s.Values = append(s.Values, v)
+	}
+	s.N++
+}
+
+// Compact reduce kept values to max (by doubling stride), so percentiles are approximate after it.
+// Compaction is deterministic: values with the same appended order are kept.
+func (s *Samples) Compact(max int) {
+	if max <= 0 {
+		return
+	}
+	for len(s.Values) > max {
+		n := 0
+		for i := 0; i < len(s.Values); i += 2 {
+			s.Values[n] = s.Values[i]
+			n++
+		}
+		s.Values = s.Values[:n]
+		if s.Stride <= 1 {
+			s.Stride = 2
+		} else {
+			s.Stride *= 2
+		}
+	}
+}
+
+type samples Samples
+
+// UnmarshalJSON decode samples (or values array from old state file)
+func (s *Samples) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '[' {
+		var values []float64
+		if err := json.Unmarshal(b, &values); err != nil {
+			return err
+		}
+		*s = Samples{}
+		for _, v := range values {
+			s.Append(v)
+		}
+		return nil
+	}
+	return json.Unmarshal(b, (*samples)(s))
+}
@@ -0,0 +1,90 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSamples_Append(t *testing.T) {
+	var s Samples
+	n := 100000
+	for i := 0; i < n; i++ {
+		s.Append(float64(i))
+	}
+	var a AggNode
+	if err := a.CalcSamples(&s); err != nil {
+		t.Fatalf("AggNode.CalcSamples() error = %v", err)
+	}
+	var want AggNode
+	if err := want.Calc(s.Values); err != nil {
+		t.Fatalf("AggNode.Calc() error = %v", err)
+	}
+	// without compaction all values are kept, percentiles are exact
+	if len(s.Values) != n || s.N != int64(n) || a != want {
+		t.Errorf("AggNode.CalcSamples() = %+v, want %+v", a, want)
+	}
+}
+
+func TestSamples_Compact(t *testing.T) {
+	n := 100000
+	compacted := func() Samples {
+		var s Samples
+		for i := 0; i < n/2; i++ {
+			s.Append(float64(i))
+		}
+		s.Compact(MaxStateSamples)
+		// continue after restore
+		for i := n / 2; i < n; i++ {
+			s.Append(float64(i))
+		}
+		s.Compact(MaxStateSamples)
+		return s
+	}
+	s := compacted()
+	if len(s.Values) > MaxStateSamples || s.N != int64(n) || s.Min != 0 || s.Max != float64(n-1) {
+		t.Fatalf("Samples{len(Values): %d, N: %d, Min: %f, Max: %f}", len(s.Values), s.N, s.Min, s.Max)
+	}
+	for i, v := range s.Values {
+		if v != float64(int64(i)*s.Stride) {
+			t.Fatalf("Samples.Values[%d] = %f, want %d", i, v, int64(i)*s.Stride)
+		}
+	}
+	var a AggNode
+	if err := a.CalcSamples(&s); err != nil {
+		t.Fatalf("AggNode.CalcSamples() error = %v", err)
+	}
+	if a.Min != 0 || a.Max != float64(n-1) || math.Abs(a.P50-float64(n)/2) > float64(n)/100 {
+		t.Errorf("AggNode.CalcSamples() = %+v", a)
+	}
+	if got := compacted(); !reflect.DeepEqual(got, s) {
+		t.Error("Samples.Compact() is not deterministic")
+	}
+}
+
+func TestSamples_UnmarshalJSON(t *testing.T) {
+	// values array from old state file
+	var s Samples
+	if err := json.Unmarshal([]byte(`[3, 1, 2]`), &s); err != nil {
+		t.Fatalf("json.Unmarshal(Samples) error = %v", err)
+	}
+	want := Samples{Values: []float64{3, 1, 2}, N: 3, Min: 1, Max: 3}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("json.Unmarshal(Samples) = %+v, want %+v", s, want)
+	}
+
+	s.Compact(2)
+	want = Samples{Values: []float64{3, 2}, N: 3, Min: 1, Max: 3, Stride: 2}
+	b, err := json.Marshal(&s)
+	if err != nil {
+		t.Fatalf("json.Marshal(Samples) error = %v", err)
+	}
+	var got Samples
+	if err = json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal(Samples) error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("json.Unmarshal(Samples) = %+v, want %+v", got, want)
+	}
+}
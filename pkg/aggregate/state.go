@@ -0,0 +1,90 @@
+package aggregate
+
+import (
+	"encoding/json"
+)
+
+type statIndexNodeState struct {
+	Node         *StatIndexNode
+	MaxReadRows  int64
+	MaxErrorTime float64
+}
+
+type statQueryNodeState struct {
+	Node         *StatQueryNode
+	MaxReadRows  int64
+	MaxErrorTime float64
+}
+
+// statSummaryState is a raw (not aggregated) summary, saved to state file for incremental aggregation
+type statSummaryState struct {
+	Index    []statIndexNodeState
+	Requests []statQueryNodeState
+	TagTerms []*TagTermNode `json:",omitempty"`
+	GroupBy  GroupBy        `json:",omitempty"`
+
+	SplitTargets bool `json:",omitempty"`
+}
+
+func (sSum *StatSummary) MarshalJSON() ([]byte, error) {
+	state := statSummaryState{
+		Index:    make([]statIndexNodeState, 0, len(sSum.Index)),
+		Requests: make([]statQueryNodeState, 0, len(sSum.Requests)),
+		TagTerms: make([]*TagTermNode, 0, len(sSum.TagTerms)),
+		GroupBy:  sSum.GroupBy,
+
+		SplitTargets: sSum.SplitTargets,
+	}
+	for _, node := range sSum.Index {
+		state.Index = append(state.Index, statIndexNodeState{
+			Node: node, MaxReadRows: node.maxReadRows, MaxErrorTime: node.maxErrorTime,
+		})
+	}
+	for _, node := range sSum.Requests {
+		state.Requests = append(state.Requests, statQueryNodeState{
+			Node: node, MaxReadRows: node.maxReadRows, MaxErrorTime: node.maxErrorTime,
+		})
+	}
+	for _, node := range sSum.TagTerms {
+		state.TagTerms = append(state.TagTerms, node)
+	}
+	return json.Marshal(state)
+}
+
+func (sSum *StatSummary) UnmarshalJSON(b []byte) error {
+	var state statSummaryState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return err
+	}
+	sSum.GroupBy = state.GroupBy
+	sSum.SplitTargets = state.SplitTargets
+	sSum.Index = NewStatIndexSummary()
+	for _, s := range state.Index {
+		if s.Node == nil {
+			continue
+		}
+		s.Node.maxReadRows = s.MaxReadRows
+		s.Node.maxErrorTime = s.MaxErrorTime
+		sSum.Index[s.Node.IndexKey] = s.Node
+	}
+	sSum.Requests = NewStatQuerySummary()
+	for _, s := range state.Requests {
+		if s.Node == nil {
+			continue
+		}
+		s.Node.maxReadRows = s.MaxReadRows
+		s.Node.maxErrorTime = s.MaxErrorTime
+		if s.Node.RequestStatus == nil {
+			s.Node.RequestStatus = make(map[int64]int64)
+		}
+		sSum.Requests[s.Node.DataKey] = s.Node
+	}
+	sSum.TagTerms = NewTagTermSummary()
+	for _, node := range state.TagTerms {
+		if node == nil {
+			continue
+		}
+		sSum.TagTerms[node.Term] = node
+	}
+	return nil
+}
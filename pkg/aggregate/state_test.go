@@ -0,0 +1,70 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+func Test_StatSummary_state(t *testing.T) {
+	stats := []*stat.Stat{
+		{
+			RequestType: "render", Id: "1", TimeStamp: 1674288343773000000,
+			Metrics: 1, Points: 4, Bytes: 148,
+			RequestStatus: 200, RequestTime: 3, QueryTime: 3,
+			ReadRows: 414 + 12284, ReadBytes: 14168 + 2497094,
+			Queries:       []stat.Query{{Query: "test.a", Days: 1, From: 1674288343 - 60, Until: 1674288343}},
+			IndexReadRows: 414, IndexReadBytes: 14168,
+			Index:        []stat.IndexStat{{Status: stat.StatusSuccess, Time: 1, ReadRows: 414, ReadBytes: 14168, Days: 1}},
+			DataReadRows: 12284, DataReadBytes: 2497094,
+			Data: []stat.DataStat{{Status: stat.StatusSuccess, Time: 2, ReadRows: 12284, ReadBytes: 2497094, Days: 1}},
+		},
+		{
+			RequestType: "render", Id: "2", TimeStamp: 1674288343773000000,
+			Metrics: 1, Points: 4, Bytes: 148,
+			RequestStatus: 200, RequestTime: 2, QueryTime: 2,
+			ReadRows: 12284, ReadBytes: 2497094,
+			Queries:      []stat.Query{{Query: "test.a", Days: 1, From: 1674288403 - 3600*25, Until: 1674288403 - 3600*24}},
+			Index:        []stat.IndexStat{{Status: stat.StatusCached, Days: 1}},
+			DataReadRows: 12284, DataReadBytes: 2497094,
+			Data: []stat.DataStat{{Status: stat.StatusSuccess, Time: 2, ReadRows: 12284, ReadBytes: 2497094, Days: 1}},
+		},
+		{
+			RequestType: "render", Id: "3", TimeStamp: 1674288343773000000,
+			RequestStatus: 504, RequestTime: 10, QueryTime: 10,
+			Queries: []stat.Query{{Query: "test.a", Days: 1, From: 1674288403 - 3600*25, Until: 1674288403 - 3600*24}},
+			Index:   []stat.IndexStat{{Status: stat.StatusError, Time: 10, Days: 1}},
+		},
+	}
+
+	statSum := NewStatSummary()
+	for _, s := range stats {
+		statSum.Append(s)
+	}
+	want := statSum.Aggregate()
+
+	// incremental: save summary after first stats, restore and append the rest
+	for n := 0; n <= len(stats); n++ {
+		partSum := NewStatSummary()
+		for _, s := range stats[:n] {
+			partSum.Append(s)
+		}
+		b, err := json.Marshal(partSum)
+		if err != nil {
+			t.Fatalf("json.Marshal(StatSummary) error = %v", err)
+		}
+		restored := NewStatSummary()
+		if err = json.Unmarshal(b, restored); err != nil {
+			t.Fatalf("json.Unmarshal(StatSummary) error = %v", err)
+		}
+		for _, s := range stats[n:] {
+			restored.Append(s)
+		}
+		if got := restored.Aggregate(); !reflect.DeepEqual(got, want) {
+			t.Errorf("restored %d StatSummary.Aggregate() = %s", n, cmp.Diff(want, got))
+		}
+	}
+}
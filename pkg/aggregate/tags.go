@@ -0,0 +1,93 @@
+package aggregate
+
+import (
+	"sort"
+
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+// TagTermNode is a tagged index queries stat, attributed to seriesByTag term of Tag1 prefilter
+// (or to Tag1 prefilter condition, if term is not found in request targets)
+type TagTermNode struct {
+	Term string
+
+	N      int64 // index queries
+	Errors int64
+
+	ReadRows    int64
+	ReadBytes   int64
+	MaxReadRows int64
+	Time        float64
+
+	SampleId string // request id with max read rows
+}
+
+// TagTermSummary is a tagged index queries stat by tag term
+type TagTermSummary map[string]*TagTermNode
+
+func NewTagTermSummary() TagTermSummary {
+	return make(TagTermSummary)
+}
+
+// Append add tagged index queries of request to tag terms stat (cached queries are skipped)
+func (tSum TagTermSummary) Append(s *stat.Stat) {
+	var terms []stat.TagTerm
+	for i := range s.Index {
+		q := &s.Index[i]
+		if !q.Tagged() || q.Status == stat.StatusCached {
+			continue
+		}
+		if terms == nil {
+			terms = make([]stat.TagTerm, 0, 4)
+			for _, query := range s.Queries {
+				terms = append(terms, query.Terms...)
+			}
+		}
+		key := q.Tag1
+		if term, ok := stat.TermOf(terms, q.Tag1); ok {
+			key = term.String()
+		}
+		node, ok := tSum[key]
+		if !ok {
+			node = &TagTermNode{Term: key}
+			tSum[key] = node
+		}
+		node.N++
+		if q.Status == stat.StatusError {
+			node.Errors++
+		}
+		node.ReadRows += q.ReadRows
+		node.ReadBytes += q.ReadBytes
+		node.Time += q.Time
+		if node.MaxReadRows < q.ReadRows || node.SampleId == "" {
+			node.MaxReadRows = q.ReadRows
+			node.SampleId = s.Id
+		}
+	}
+}
+
+// Aggregate return tag terms, sorted by read rows (most expensive first)
+func (tSum TagTermSummary) Aggregate() []*TagTermNode {
+	if len(tSum) == 0 {
+		return nil
+	}
+	nodes := make([]*TagTermNode, 0, len(tSum))
+	for _, node := range tSum {
+		nodes = append(nodes, node)
+	}
+	SortTagTerms(nodes)
+	return nodes
+}
+
+// SortTagTerms sort tag terms by read rows (most expensive first)
+func SortTagTerms(nodes []*TagTermNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].ReadRows == nodes[j].ReadRows {
+			if nodes[i].Time == nodes[j].Time {
+				return nodes[i].Term < nodes[j].Term
+			}
+			return nodes[i].Time > nodes[j].Time
+		}
+		return nodes[i].ReadRows > nodes[j].ReadRows
+	})
+}
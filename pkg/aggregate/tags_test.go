@@ -0,0 +1,61 @@
+package aggregate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+func Test_TagTermSummary(t *testing.T) {
+	terms := []stat.TagTerm{{Key: "__name__", Op: stat.TagEq, Value: "test"}, {Key: "app", Op: stat.TagMatch, Value: "ch.*"}}
+	stats := []*stat.Stat{
+		{
+			Id: "1", RequestType: "render",
+			Queries: []stat.Query{{Query: "seriesByTag('name=test', 'app=~ch.*')", Terms: terms}},
+			Index: []stat.IndexStat{
+				{Status: stat.StatusSuccess, ReadRows: 100, ReadBytes: 1000, Time: 0.5, Table: "graphite_tagged", Tag1: "Tag1='__name__=test'"},
+				{Status: stat.StatusSuccess, ReadRows: 10000, ReadBytes: 100000, Time: 2, Table: "graphite_tagged", Tag1: "Tag1 LIKE 'app=ch%'"},
+			},
+		},
+		{
+			Id: "2", RequestType: "render",
+			Queries: []stat.Query{{Query: "seriesByTag('name=test', 'app=~ch.*')", Terms: terms}},
+			Index: []stat.IndexStat{
+				{Status: stat.StatusError, ReadRows: 200, ReadBytes: 2000, Time: 1, Table: "graphite_tagged", Tag1: "Tag1='__name__=test'"},
+				{Status: stat.StatusCached},
+			},
+		},
+		{
+			// term not parsed from target
+			Id: "3", RequestType: "render",
+			Queries: []stat.Query{{Query: "seriesByTag('dc=a')"}},
+			Index: []stat.IndexStat{
+				{Status: stat.StatusSuccess, ReadRows: 50, ReadBytes: 500, Time: 0.1, Table: "graphite_tagged", Tag1: "Tag1='dc=a'"},
+			},
+		},
+		{
+			// tag autocomplete is not a tagged index query
+			Id: "5", RequestType: "tag_values",
+			Index: []stat.IndexStat{{Status: stat.StatusSuccess, ReadRows: 1e6, Table: "graphite_tagsd"}},
+		},
+		{
+			Id: "4", RequestType: "render",
+			Queries: []stat.Query{{Query: "test.a"}},
+			Index:   []stat.IndexStat{{Status: stat.StatusSuccess, ReadRows: 1e6, Table: "graphite_indexd"}},
+		},
+	}
+
+	statSum := NewStatSummary()
+	for _, s := range stats {
+		statSum.Append(s)
+	}
+	want := []*TagTermNode{
+		{Term: "app=~ch.*", N: 1, ReadRows: 10000, ReadBytes: 100000, MaxReadRows: 10000, Time: 2, SampleId: "1"},
+		{Term: "__name__=test", N: 2, Errors: 1, ReadRows: 300, ReadBytes: 3000, MaxReadRows: 200, Time: 1.5, SampleId: "2"},
+		{Term: "Tag1='dc=a'", N: 1, ReadRows: 50, ReadBytes: 500, MaxReadRows: 50, Time: 0.1, SampleId: "3"},
+	}
+	if got := statSum.Aggregate().TagTerms; !reflect.DeepEqual(got, want) {
+		t.Errorf("StatSummary.Aggregate().TagTerms =\n%+v\nwant\n%+v", got, want)
+	}
+}
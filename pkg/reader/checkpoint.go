@@ -0,0 +1,157 @@
+package reader
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+var ErrCheckpointCompressed = errors.New("checkpoint is not supported for compressed log")
+
+// Checkpoint is a position of incremental read of growing log file
+type Checkpoint struct {
+	Path   string `json:"path"`
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+
+	// in-flight (not completed) requests
+	Queries map[string]*stat.Stat `json:"queries"`
+
+	// rotated file with previous checkpoint inode, which rest is read before log (not saved)
+	Rotated string `json:"-"`
+	// log is rotated, but rotated file is not found (or compressed), so lines after previous checkpoint are lost (not saved)
+	Gap bool `json:"-"`
+}
+
+type sectionReadCloser struct {
+	io.Reader
+	files []*os.File
+}
+
+func (s sectionReadCloser) Close() error {
+	var err error
+	for _, f := range s.files {
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// findRotated return rotated log file (like path.1 or path-20230121) with inode or empty string, if not found
+func findRotated(path string, ino uint64) string {
+	for _, pattern := range []string{path + ".*", path + "-*"} {
+		names, _ := filepath.Glob(pattern)
+		for _, name := range names {
+			if fi, err := os.Stat(name); err == nil && fi.Mode().IsRegular() && inode(fi) == ino {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// openRotated open rest of rotated log file from offset up to the last complete line
+func openRotated(path string, offset int64) (*os.File, io.Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if offset > fi.Size() {
+		// truncated before rotation
+		offset = fi.Size()
+	}
+	end, err := lastLineEnd(f, offset, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, io.NewSectionReader(f, offset, end-offset), nil
+}
+
+// lastLineEnd return offset after last new line in [start, end) range of file or start, if not found
+func lastLineEnd(f io.ReaderAt, start, end int64) (int64, error) {
+	buf := make([]byte, 64*1024)
+	for end > start {
+		pos := end - int64(len(buf))
+		if pos < start {
+			pos = start
+		}
+		n, err := f.ReadAt(buf[:end-pos], pos)
+		if err != nil && err != io.EOF {
+			return start, err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			return pos + int64(i) + 1, nil
+		}
+		end = pos
+	}
+	return start, nil
+}
+
+// OpenCheckpoint open log file for incremental read from checkpoint position up to the last complete line.
+// File is read from the start if it's rotated (inode changed) or truncated. On rotation the rest of rotated file
+// (found by inode near log, like path.1) is read before, if not found (or compressed), Gap is set in next checkpoint.
+// Return reader and next checkpoint (in-flight queries are copied from previous checkpoint and must be updated after read).
+func OpenCheckpoint(path string, cp *Checkpoint) (io.ReadCloser, *Checkpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	header := make([]byte, len(magicXz))
+	n, _ := f.ReadAt(header, 0)
+	if DetectCompression(header[:n]) != CompressionNone {
+		f.Close()
+		return nil, nil, ErrCheckpointCompressed
+	}
+
+	next := &Checkpoint{Path: path, Inode: inode(fi)}
+	var (
+		files   = []*os.File{f}
+		readers []io.Reader
+	)
+	if cp != nil {
+		next.Queries = cp.Queries
+		if cp.Inode == next.Inode {
+			if cp.Offset <= fi.Size() {
+				next.Offset = cp.Offset
+			}
+		} else if next.Rotated = findRotated(path, cp.Inode); next.Rotated == "" {
+			next.Gap = true
+		} else {
+			rf, rr, err := openRotated(next.Rotated, cp.Offset)
+			if err != nil {
+				f.Close()
+				return nil, nil, err
+			}
+			files = append(files, rf)
+			readers = append(readers, rr)
+		}
+	}
+	if next.Queries == nil {
+		next.Queries = make(map[string]*stat.Stat)
+	}
+
+	start := next.Offset
+	if next.Offset, err = lastLineEnd(f, start, fi.Size()); err != nil {
+		sectionReadCloser{files: files}.Close()
+		return nil, nil, err
+	}
+	readers = append(readers, io.NewSectionReader(f, start, next.Offset-start))
+
+	return sectionReadCloser{Reader: io.MultiReader(readers...), files: files}, next, nil
+}
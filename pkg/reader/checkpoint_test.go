@@ -0,0 +1,106 @@
+package reader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readCheckpoint(t *testing.T, path string, cp *Checkpoint) ([]string, *Checkpoint) {
+	in, next, err := OpenCheckpoint(path, cp)
+	if err != nil {
+		t.Fatalf("OpenCheckpoint() error = %v", err)
+	}
+	defer in.Close()
+
+	r := New(in, Config{Queries: next.Queries})
+	var ids []string
+	for r.Next(context.Background()) {
+		ids = append(ids, r.Stat().Id)
+	}
+	if err = r.Err(); err != nil {
+		t.Fatalf("Reader.Err() = %v", err)
+	}
+	next.Queries = r.Queries()
+	return ids, next
+}
+
+func TestOpenCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	content := strings.Join(testLog, "\n") + "\n"
+	// request fd3e9fd09a92bc3b7fb0d597f901e953 is completed in the second part, last line of first part is incomplete
+	split := strings.Index(content, testLog[3]) + 10
+
+	appendFile(t, path, content[:split])
+	ids, cp := readCheckpoint(t, path, nil)
+	if len(ids) != 0 {
+		t.Errorf("first read ids = %v, want none", ids)
+	}
+	if wantOffset := int64(strings.Index(content, testLog[3])); cp.Offset != wantOffset {
+		t.Errorf("first read offset = %d, want %d", cp.Offset, wantOffset)
+	}
+	if len(cp.Queries) != 2 {
+		t.Errorf("first read queries = %d, want 2", len(cp.Queries))
+	}
+
+	appendFile(t, path, content[split:])
+	ids, cp = readCheckpoint(t, path, cp)
+	wantIds := []string{"fd3e9fd09a92bc3b7fb0d597f901e953", "c9ec01a8b31079bfdfbc530a845f279c"}
+	if !reflect.DeepEqual(ids, wantIds) {
+		t.Errorf("second read ids = %v, want %v", ids, wantIds)
+	}
+	if cp.Offset != int64(len(content)) {
+		t.Errorf("second read offset = %d, want %d", cp.Offset, len(content))
+	}
+	if _, ok := cp.Queries["d4b7d5686f514502c362bafa608ca91b"]; !ok || len(cp.Queries) != 1 {
+		t.Errorf("second read queries = %v, want only d4b7d5686f514502c362bafa608ca91b", cp.Queries)
+	}
+
+	// no new lines
+	ids, cp = readCheckpoint(t, path, cp)
+	if len(ids) != 0 || cp.Offset != int64(len(content)) {
+		t.Errorf("third read ids = %v, offset = %d, want none, %d", ids, cp.Offset, len(content))
+	}
+
+	// rotation, rest of rotated file is read before new log from the start
+	appendFile(t, path, testLog[0]+"\n"+testLog[3]+"\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, testLog[2]+"\n"+testLog[6]+"\n")
+	ids, cp = readCheckpoint(t, path, cp)
+	if !reflect.DeepEqual(ids, wantIds) {
+		t.Errorf("rotated read ids = %v, want %v", ids, wantIds)
+	}
+	if cp.Rotated != path+".1" || cp.Gap {
+		t.Errorf("rotated read rotated = %q, gap = %t, want %q, false", cp.Rotated, cp.Gap, path+".1")
+	}
+	if wantOffset := int64(len(testLog[2]) + len(testLog[6]) + 2); cp.Offset != wantOffset {
+		t.Errorf("rotated read offset = %d, want %d", cp.Offset, wantOffset)
+	}
+
+	// rotation, rotated file is not found (moved away)
+	if err := os.Rename(path, filepath.Join(t.TempDir(), "test.log.2")); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, testLog[1]+"\n"+testLog[2]+"\n")
+	_, cp = readCheckpoint(t, path, cp)
+	if cp.Rotated != "" || !cp.Gap {
+		t.Errorf("moved read rotated = %q, gap = %t, want none, true", cp.Rotated, cp.Gap)
+	}
+	if wantOffset := int64(len(testLog[1]) + len(testLog[2]) + 2); cp.Offset != wantOffset {
+		t.Errorf("moved read offset = %d, want %d", cp.Offset, wantOffset)
+	}
+
+	// compressed log
+	gzPath := filepath.Join(t.TempDir(), "test.log.gz")
+	if err := os.WriteFile(gzPath, compressGzip(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := OpenCheckpoint(gzPath, nil); err != ErrCheckpointCompressed {
+		t.Errorf("OpenCheckpoint(compressed) error = %v, want %v", err, ErrCheckpointCompressed)
+	}
+}
@@ -0,0 +1,132 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+type Compression int8
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZstd
+	CompressionBzip2
+	CompressionXz
+)
+
+var compressionStrings []string = []string{"none", "gzip", "zstd", "bzip2", "xz"}
+
+func (c Compression) String() string {
+	return compressionStrings[c]
+}
+
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	magicBzip2 = []byte("BZh")
+	magicXz    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// DetectCompression detect compression by magic bytes
+func DetectCompression(header []byte) Compression {
+	switch {
+	case bytes.HasPrefix(header, magicGzip):
+		return CompressionGzip
+	case bytes.HasPrefix(header, magicZstd):
+		return CompressionZstd
+	case bytes.HasPrefix(header, magicBzip2):
+		return CompressionBzip2
+	case bytes.HasPrefix(header, magicXz):
+		return CompressionXz
+	default:
+		return CompressionNone
+	}
+}
+
+type decompressReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decompressReader) Close() (err error) {
+	for _, c := range d.closers {
+		if cErr := c.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	return
+}
+
+type zstdCloser struct {
+	d *zstd.Decoder
+}
+
+func (z zstdCloser) Close() error {
+	z.d.Close()
+	return nil
+}
+
+// Decompress detect compression of input stream and return decompressed reader (close also close input)
+func Decompress(in io.ReadCloser) (io.ReadCloser, Compression, error) {
+	br := bufio.NewReaderSize(in, 64*1024)
+	header, _ := br.Peek(len(magicXz))
+
+	d := &decompressReader{closers: []io.Closer{in}}
+	compression := DetectCompression(header)
+	switch compression {
+	case CompressionGzip:
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, compression, err
+		}
+		d.Reader = gz
+		d.closers = append([]io.Closer{gz}, d.closers...)
+	case CompressionZstd:
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, compression, err
+		}
+		d.Reader = zr
+		d.closers = append([]io.Closer{zstdCloser{zr}}, d.closers...)
+	case CompressionBzip2:
+		d.Reader = bzip2.NewReader(br)
+	case CompressionXz:
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, compression, err
+		}
+		d.Reader = xr
+	default:
+		d.Reader = br
+	}
+
+	return d, compression, nil
+}
+
+// Open open log file (or stdin for empty path) with transparent decompression
+func Open(path string) (io.ReadCloser, error) {
+	var in io.ReadCloser
+	if path == "" {
+		in = io.NopCloser(os.Stdin)
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		in = f
+	}
+	r, _, err := Decompress(in)
+	if err != nil {
+		in.Close()
+		return nil, err
+	}
+	return r, nil
+}
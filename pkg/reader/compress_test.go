@@ -0,0 +1,103 @@
+package reader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const testContent = "line 1\nline 2\n"
+
+func compressGzip(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(testContent)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func compressZstd(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(testContent)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func compressXz(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w, err := xz.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(testContent)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func compressBzip2(t *testing.T) []byte {
+	// compress/bzip2 has no writer, content compressed with python bz2
+	b, err := base64.StdEncoding.DecodeString("QlpoOTFBWSZTWTGIIWgAAAVZAAAQQAAwAAIlIAAxDAgShkaJMZCHEPF3JFOFCQMYghaA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecompress(t *testing.T) {
+	tests := []struct {
+		name            string
+		compress        func(t *testing.T) []byte
+		wantCompression Compression
+	}{
+		{
+			name:            "none",
+			compress:        func(t *testing.T) []byte { return []byte(testContent) },
+			wantCompression: CompressionNone,
+		},
+		{name: "gzip", compress: compressGzip, wantCompression: CompressionGzip},
+		{name: "zstd", compress: compressZstd, wantCompression: CompressionZstd},
+		{name: "bzip2", compress: compressBzip2, wantCompression: CompressionBzip2},
+		{name: "xz", compress: compressXz, wantCompression: CompressionXz},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := io.NopCloser(bytes.NewReader(tt.compress(t)))
+			r, compression, err := Decompress(in)
+			if err != nil {
+				t.Fatalf("Decompress() error = %v", err)
+			}
+			defer r.Close()
+			if compression != tt.wantCompression {
+				t.Errorf("Decompress() compression = %s, want %s", compression, tt.wantCompression)
+			}
+			b, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("Decompress() read error = %v", err)
+			}
+			if string(b) != testContent {
+				t.Errorf("Decompress() = %q, want %q", string(b), testContent)
+			}
+		})
+	}
+}
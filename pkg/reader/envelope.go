@@ -0,0 +1,289 @@
+package reader
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+// Envelope is a log line envelope (container runtime or journald record), wrapped graphite-clickhouse log entry
+type Envelope int8
+
+const (
+	EnvelopeAuto     Envelope = iota // detect by first line
+	EnvelopeNone                     // plain graphite-clickhouse log
+	EnvelopeDocker                   // docker json-file: {"log":"...","stream":"stdout","time":"..."}
+	EnvelopeCRI                      // kubernetes CRI: <time> <stream> <P|F> <log>
+	EnvelopeJournald                 // journalctl -o json: {"MESSAGE":"...","_HOSTNAME":"..."}
+)
+
+var envelopeStrings []string = []string{"auto", "none", "docker", "cri", "journald"}
+
+func (e Envelope) String() string {
+	return envelopeStrings[e]
+}
+
+// EnvelopeStrings return supported envelopes
+func EnvelopeStrings() []string {
+	return envelopeStrings
+}
+
+// ParseEnvelope return envelope by name
+func ParseEnvelope(s string) (Envelope, error) {
+	if s == "" {
+		return EnvelopeAuto, nil
+	}
+	for i, name := range envelopeStrings {
+		if s == name {
+			return Envelope(i), nil
+		}
+	}
+	return EnvelopeAuto, errors.New("unknown envelope: " + s)
+}
+
+// detectEnvelope detect envelope by log line: CRI prefix or top-level keys of json record
+// (graphite-clickhouse log entry with request_id or logger is never an envelope)
+func detectEnvelope(line []byte) Envelope {
+	line = bytes.TrimLeft(line, " \t")
+	if len(line) == 0 {
+		return EnvelopeAuto
+	}
+	if line[0] >= '0' && line[0] <= '9' {
+		if _, _, _, ok := splitCRI(line); ok {
+			return EnvelopeCRI
+		}
+	} else if line[0] == '{' {
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(line, &keys); err != nil {
+			return EnvelopeNone
+		}
+		has := func(key string) bool {
+			_, ok := keys[key]
+			return ok
+		}
+		switch {
+		case has("request_id") || has("logger"):
+			return EnvelopeNone
+		case has("MESSAGE") && (has("_HOSTNAME") || has("__REALTIME_TIMESTAMP")):
+			return EnvelopeJournald
+		case has("log") && has("stream"):
+			return EnvelopeDocker
+		}
+	}
+	return EnvelopeNone
+}
+
+// splitCRI split CRI log line to stream, tag and log
+func splitCRI(line []byte) (stream, tag, log []byte, ok bool) {
+	fields := bytes.SplitN(line, []byte{' '}, 4)
+	if len(fields) < 3 {
+		return nil, nil, nil, false
+	}
+	stream = fields[1]
+	if !bytes.Equal(stream, []byte("stdout")) && !bytes.Equal(stream, []byte("stderr")) {
+		return nil, nil, nil, false
+	}
+	tag = fields[2]
+	if len(fields) == 4 {
+		log = fields[3]
+	}
+	return stream, tag, log, true
+}
+
+type dockerRecord struct {
+	Log   string            `json:"log"`
+	Attrs map[string]string `json:"attrs"`
+}
+
+type journaldRecord struct {
+	Message  string `json:"MESSAGE"`
+	Hostname string `json:"_HOSTNAME"`
+}
+
+// envelopeSource unwrap graphite-clickhouse log entries from envelope lines, partial lines (splitted by container runtime) are joined
+type envelopeSource struct {
+	lineSource
+	envelope    Envelope
+	instance    string // input instance label
+	maxLineSize int    // max joined line size
+
+	line         []byte
+	partial      []byte
+	lineErr      error
+	lineInstance string
+}
+
+func newEnvelopeSource(src lineSource, envelope Envelope, instance string, maxLineSize int) lineSource {
+	if envelope == EnvelopeNone && instance == "" {
+		return src
+	}
+	if maxLineSize == 0 {
+		maxLineSize = DefaultMaxLineSize
+	}
+	return &envelopeSource{lineSource: src, envelope: envelope, instance: instance, maxLineSize: maxLineSize}
+}
+
+func (s *envelopeSource) Scan() bool {
+	s.partial = s.partial[:0]
+	var size int // joined partial lines size
+	for s.lineSource.Scan() {
+		s.lineErr = s.lineSource.LineErr()
+		s.lineInstance = s.instance
+		line := s.lineSource.Bytes()
+		if s.lineErr != nil {
+			// too long line, can't be unwrapped
+			s.line = line
+			return true
+		}
+		if s.envelope == EnvelopeAuto {
+			s.envelope = detectEnvelope(line)
+			if s.envelope == EnvelopeAuto {
+				// empty line
+				s.line = line
+				return true
+			}
+		}
+		var (
+			partial bool
+			err     error
+		)
+		switch s.envelope {
+		case EnvelopeDocker:
+			line, partial, err = s.unwrapDocker(line)
+		case EnvelopeCRI:
+			line, partial, err = s.unwrapCRI(line)
+		case EnvelopeJournald:
+			line, err = s.unwrapJournald(line)
+		}
+		if err != nil {
+			s.lineErr = &stat.ParseError{Reason: stat.ReasonInvalidEnvelope, Msg: s.envelope.String() + ": " + err.Error()}
+			s.line = line
+			return true
+		}
+		if partial || size > 0 {
+			// joined line is truncated to max line size, like long line in scanner
+			size += len(line)
+			if s.maxLineSize < 0 || size <= s.maxLineSize {
+				s.partial = append(s.partial, line...)
+			} else if len(s.partial) < s.maxLineSize {
+				s.partial = append(s.partial, line[:s.maxLineSize-len(s.partial)]...)
+			}
+			if partial {
+				continue
+			}
+			line = s.partial
+		}
+		s.line = line
+		s.lineErr = s.joinedErr(size)
+		return true
+	}
+	if size > 0 {
+		// incomplete last line
+		s.line = s.partial
+		s.lineErr = s.joinedErr(size)
+		return true
+	}
+	return false
+}
+
+// joinedErr return error for joined partial lines, longer than max line size
+func (s *envelopeSource) joinedErr(size int) error {
+	if s.maxLineSize > 0 && size > s.maxLineSize {
+		return &stat.ParseError{
+			Reason: stat.ReasonLineTooLong,
+			Msg:    "joined line size " + strconv.Itoa(size) + " exceeds " + strconv.Itoa(s.maxLineSize),
+		}
+	}
+	return nil
+}
+
+func (s *envelopeSource) unwrapDocker(line []byte) ([]byte, bool, error) {
+	var rec dockerRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return line, false, err
+	}
+	if pod := rec.Attrs["io.kubernetes.pod.name"]; pod != "" {
+		s.lineInstance = pod
+	} else if tag := rec.Attrs["tag"]; tag != "" {
+		s.lineInstance = tag
+	}
+	// docker split long lines, only last chunk is ended with newline
+	if strings.HasSuffix(rec.Log, "\n") {
+		return []byte(strings.TrimRight(rec.Log, "\r\n")), false, nil
+	}
+	return []byte(rec.Log), true, nil
+}
+
+func (s *envelopeSource) unwrapCRI(line []byte) ([]byte, bool, error) {
+	_, tag, log, ok := splitCRI(line)
+	if !ok {
+		return line, false, errors.New("malformed line")
+	}
+	// tags are separated by ':', first is P (partial) or F (full)
+	partial := len(tag) > 0 && tag[0] == 'P'
+	return log, partial, nil
+}
+
+func (s *envelopeSource) unwrapJournald(line []byte) ([]byte, error) {
+	var rec journaldRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return line, err
+	}
+	if rec.Hostname != "" {
+		s.lineInstance = rec.Hostname
+	}
+	return []byte(rec.Message), nil
+}
+
+func (s *envelopeSource) Bytes() []byte {
+	return s.line
+}
+
+func (s *envelopeSource) LineErr() error {
+	return s.lineErr
+}
+
+func (s *envelopeSource) Instance() string {
+	return s.lineInstance
+}
+
+// InstanceFromPath return kubernetes pod name or docker container id from container log path
+// (/var/log/containers/<pod>_<namespace>_<container>-<id>.log, /var/log/pods/<namespace>_<pod>_<uid>/<container>/0.log,
+// /var/lib/docker/containers/<id>/<id>-json.log), empty string for other paths
+func InstanceFromPath(path string) string {
+	path = filepath.ToSlash(path)
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	switch {
+	case strings.HasSuffix(dir, "/log/containers"):
+		if pod, _, ok := strings.Cut(name, "_"); ok {
+			return pod
+		}
+	case strings.Contains(dir, "/log/pods/"):
+		podDir := filepath.Base(filepath.Dir(dir))
+		if parts := strings.SplitN(podDir, "_", 3); len(parts) == 3 {
+			return parts[1]
+		}
+	case strings.HasSuffix(filepath.Dir(dir), "/docker/containers"):
+		if id := filepath.Base(dir); strings.HasPrefix(name, id+"-json.log") {
+			if len(id) > 12 {
+				id = id[:12]
+			}
+			return id
+		}
+	}
+	return ""
+}
+
+// InstanceFromFileName return file name without extensions as instance label, like host1 for /var/log/host1.log.1.gz
+func InstanceFromFileName(path string) string {
+	name := filepath.Base(path)
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
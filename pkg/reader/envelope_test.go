@@ -0,0 +1,299 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+func dockerLine(line, attrs string) string {
+	return dockerChunk(line+"\n", attrs)
+}
+
+// dockerChunk return docker json-file record, log without trailing newline is a partial line
+func dockerChunk(log, attrs string) string {
+	b, _ := json.Marshal(log)
+	s := `{"log":` + string(b) + `,"stream":"stderr","time":"2023-01-21T08:06:20.528123456Z"`
+	if attrs != "" {
+		s += `,"attrs":` + attrs
+	}
+	return s + "}"
+}
+
+func criLine(line string) string {
+	return "2023-01-21T08:06:20.528123456Z stderr F " + line
+}
+
+func journaldLine(line, host string) string {
+	b, _ := json.Marshal(line)
+	return `{"__CURSOR":"s=1","_HOSTNAME":"` + host + `","SYSLOG_IDENTIFIER":"graphite-clickhouse","MESSAGE":` + string(b) + `}`
+}
+
+func wrapLog(lines []string, wrap func(string) string) string {
+	wrapped := make([]string, len(lines))
+	for i, line := range lines {
+		wrapped[i] = wrap(line)
+	}
+	return strings.Join(wrapped, "\n")
+}
+
+func TestDetectEnvelope(t *testing.T) {
+	tests := []struct {
+		line string
+		want Envelope
+	}{
+		{line: testLog[0], want: EnvelopeNone},
+		{line: "not a json", want: EnvelopeNone},
+		{line: "", want: EnvelopeAuto},
+		{line: dockerLine(testLog[0], ""), want: EnvelopeDocker},
+		{line: criLine(testLog[0]), want: EnvelopeCRI},
+		{line: "2023-01-21 13:06:20 INFO started", want: EnvelopeNone},
+		{line: journaldLine(testLog[0], "host1"), want: EnvelopeJournald},
+		{line: `{"__REALTIME_TIMESTAMP":"1674288380528123","MESSAGE":"started"}`, want: EnvelopeJournald},
+		{line: `{"MESSAGE":"started"}`, want: EnvelopeNone},
+		{line: `{"log":"started\n"}`, want: EnvelopeNone},
+		{line: `{"log":"started\n","stream":"stderr"`, want: EnvelopeNone},
+		// graphite-clickhouse entries with envelope keys in fields or values
+		{line: `{"level":"INFO","logger":"http","message":"access","request_id":"1","url":"/render/?target=a&log=1","log":"x","stream":"stdout"}`, want: EnvelopeNone},
+		{line: `{"level":"INFO","message":"started","request_id":"1","MESSAGE":"x","_HOSTNAME":"host1"}`, want: EnvelopeNone},
+		{line: `{"level":"INFO","logger":"main","message":"\"log\": \"MESSAGE\":"}`, want: EnvelopeNone},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if got := detectEnvelope([]byte(tt.line)); got != tt.want {
+				t.Errorf("detectEnvelope() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_Envelope(t *testing.T) {
+	wantIds := []string{"fd3e9fd09a92bc3b7fb0d597f901e953", "c9ec01a8b31079bfdfbc530a845f279c"}
+	tests := []struct {
+		name         string
+		in           string
+		envelope     Envelope
+		instance     string
+		wantInstance string
+	}{
+		{
+			name:         "docker",
+			in:           wrapLog(testLog, func(s string) string { return dockerLine(s, "") }),
+			instance:     "graphite-clickhouse-0",
+			wantInstance: "graphite-clickhouse-0",
+		},
+		{
+			name:         "docker attrs",
+			in:           wrapLog(testLog, func(s string) string { return dockerLine(s, `{"io.kubernetes.pod.name":"graphite-clickhouse-1"}`) }),
+			envelope:     EnvelopeDocker,
+			instance:     "graphite-clickhouse-0",
+			wantInstance: "graphite-clickhouse-1",
+		},
+		{
+			name:         "cri",
+			in:           wrapLog(testLog, criLine),
+			instance:     "graphite-clickhouse-0",
+			wantInstance: "graphite-clickhouse-0",
+		},
+		{
+			name:         "journald",
+			in:           wrapLog(testLog, func(s string) string { return journaldLine(s, "host1") }),
+			envelope:     EnvelopeJournald,
+			wantInstance: "host1",
+		},
+		{
+			name: "plain",
+			in:   strings.Join(testLog, "\n"),
+		},
+	}
+	for _, tt := range tests {
+		for _, workers := range []int{1, 4} {
+			t.Run(tt.name+"#"+strconv.Itoa(workers), func(t *testing.T) {
+				r := New(strings.NewReader(tt.in), Config{Envelope: tt.envelope, Instances: []string{tt.instance}, Workers: workers})
+				ids := make([]string, 0, len(wantIds))
+				for r.Next(context.Background()) {
+					ids = append(ids, r.Stat().Id)
+					if r.Stat().Instance != tt.wantInstance {
+						t.Errorf("Stat(%s).Instance = %q, want %q", r.Stat().Id, r.Stat().Instance, tt.wantInstance)
+					}
+				}
+				if err := r.Err(); err != nil {
+					t.Fatalf("Reader.Err() = %v", err)
+				}
+				if strings.Join(ids, ",") != strings.Join(wantIds, ",") {
+					t.Errorf("Reader ids = %v, want %v", ids, wantIds)
+				}
+				if got := r.Counters(); got != (Counters{Lines: 8, Skipped: 2, Bad: 1, Completed: 2}) {
+					t.Errorf("Reader.Counters() = %+v", got)
+				}
+			})
+		}
+	}
+}
+
+func TestReader_EnvelopePartial(t *testing.T) {
+	// container runtime split long lines
+	line := testLog[3]
+	tests := []struct {
+		name     string
+		envelope Envelope
+		lines    []string
+	}{
+		{
+			name:     "docker",
+			envelope: EnvelopeDocker,
+			lines: []string{
+				dockerLine(testLog[0], ""),
+				dockerChunk(line[:100], ""),
+				dockerLine(line[100:], ""),
+			},
+		},
+		{
+			name:     "cri",
+			envelope: EnvelopeCRI,
+			lines: []string{
+				criLine(testLog[0]),
+				"2023-01-21T08:06:20.528123456Z stderr P " + line[:100],
+				"2023-01-21T08:06:20.528123456Z stderr P " + line[100:200],
+				criLine(line[200:]),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(strings.NewReader(strings.Join(tt.lines, "\n")), Config{Envelope: tt.envelope})
+			var ids []string
+			for r.Next(context.Background()) {
+				ids = append(ids, r.Stat().Id)
+			}
+			if err := r.Err(); err != nil {
+				t.Fatalf("Reader.Err() = %v", err)
+			}
+			if len(ids) != 1 || ids[0] != "fd3e9fd09a92bc3b7fb0d597f901e953" {
+				t.Errorf("Reader ids = %v", ids)
+			}
+			if got := r.Counters(); got.Bad != 0 {
+				t.Errorf("Reader.Counters() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestReader_EnvelopePartialLong(t *testing.T) {
+	// joined partial lines are limited by max line size
+	line := testLog[3]
+	n := len(line) / 3
+	tests := []struct {
+		name     string
+		envelope Envelope
+		lines    []string
+	}{
+		{
+			name:     "docker",
+			envelope: EnvelopeDocker,
+			lines: []string{
+				dockerChunk(line[:n], ""),
+				dockerChunk(line[n:2*n], ""),
+				dockerLine(line[2*n:], ""),
+				dockerLine(testLog[5], ""),
+			},
+		},
+		{
+			name:     "cri",
+			envelope: EnvelopeCRI,
+			lines: []string{
+				"2023-01-21T08:06:20.528123456Z stderr P " + line[:n],
+				"2023-01-21T08:06:20.528123456Z stderr P " + line[n:2*n],
+				criLine(line[2*n:]),
+				criLine(testLog[5]),
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, workers := range []int{1, 2} {
+			t.Run(tt.name+"#"+strconv.Itoa(workers), func(t *testing.T) {
+				in := strings.Join(tt.lines, "\n")
+				r := New(strings.NewReader(in), Config{Envelope: tt.envelope, MaxLineSize: len(line) - 1, Workers: workers})
+				for r.Next(context.Background()) {
+				}
+				if err := r.Err(); err != nil {
+					t.Fatalf("Reader.Err() = %v", err)
+				}
+				if got := r.Counters(); got != (Counters{Lines: 2, Skipped: 2, Bad: 1}) {
+					t.Errorf("Reader.Counters() = %+v", got)
+				}
+				bad := r.BadLines()[stat.ReasonLineTooLong]
+				if bad == nil || bad.Count != 1 || bad.Samples[0].Line != 3 {
+					t.Errorf("Reader.BadLines()[line_too_long] = %+v", bad)
+				}
+
+				r = New(strings.NewReader(in), Config{Envelope: tt.envelope, MaxLineSize: len(line) - 1, Workers: workers, Strict: true})
+				for r.Next(context.Background()) {
+				}
+				var pErr *stat.ParseError
+				if !errors.As(r.Err(), &pErr) || pErr.Reason != stat.ReasonLineTooLong {
+					t.Errorf("strict Reader.Err() = %v, want %s", r.Err(), stat.ReasonLineTooLong)
+				}
+			})
+		}
+	}
+}
+
+func TestReader_EnvelopeBad(t *testing.T) {
+	in := strings.Join([]string{criLine(testLog[0]), "2023-01-21T08:06:20.528123456Z", criLine(testLog[3])}, "\n")
+	r := New(strings.NewReader(in), Config{Envelope: EnvelopeCRI})
+	for r.Next(context.Background()) {
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("Reader.Err() = %v", err)
+	}
+	bad := r.BadLines()
+	if len(bad) != 1 || bad[stat.ReasonInvalidEnvelope] == nil || bad[stat.ReasonInvalidEnvelope].Samples[0].Line != 2 {
+		t.Errorf("Reader.BadLines() = %+v", bad)
+	}
+}
+
+func TestInstanceFromPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/var/log/containers/graphite-clickhouse-0_graphite_graphite-clickhouse-5d1c2f0e.log", want: "graphite-clickhouse-0"},
+		{path: "/var/log/pods/graphite_graphite-clickhouse-0_6b5a9d3c-1111/graphite-clickhouse/0.log", want: "graphite-clickhouse-0"},
+		{path: "/var/log/pods/graphite_graphite-clickhouse-0_6b5a9d3c-1111/graphite-clickhouse/0.log.20230121-130000.gz", want: "graphite-clickhouse-0"},
+		{path: "/var/lib/docker/containers/5d1c2f0e3b7a9c11aa/5d1c2f0e3b7a9c11aa-json.log", want: "5d1c2f0e3b7a"},
+		{path: "/var/lib/docker/containers/5d1c2f0e3b7a9c11aa/5d1c2f0e3b7a9c11aa-json.log.1", want: "5d1c2f0e3b7a"},
+		{path: "/var/log/graphite-clickhouse/graphite-clickhouse.log"},
+		{path: "graphite-clickhouse.log"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := InstanceFromPath(tt.path); got != tt.want {
+				t.Errorf("InstanceFromPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstanceFromFileName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/var/log/graphite-clickhouse/host1.log", want: "host1"},
+		{path: "/var/log/graphite-clickhouse/host1.log.1.gz", want: "host1"},
+		{path: "host2", want: "host2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := InstanceFromFileName(tt.path); got != tt.want {
+				t.Errorf("InstanceFromFileName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultPollInterval is a default interval for check followed file for new lines and rotation
+const DefaultPollInterval = 250 * time.Millisecond
+
+// Follower read growing log file (like tail -F). Read is started from the end of file (existing complete lines are skipped)
+// or from the start, if requested. On end of file it's wait for new lines,
+// file is reopened after rename rotation and read from the start after truncate (copytruncate rotation).
+// Read is stopped by context cancel.
+type Follower struct {
+	ctx  context.Context
+	path string
+	poll time.Duration
+
+	f       *os.File
+	offset  int64
+	renamed bool // file rotation detected, read rest of old file before reopen
+}
+
+// NewFollower open log file for follow. If fromStart is false, existing complete lines are skipped (like tail -F),
+// incomplete last line is read.
+func NewFollower(ctx context.Context, path string, poll time.Duration, fromStart bool) (*Follower, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if poll <= 0 {
+		poll = DefaultPollInterval
+	}
+	fl := &Follower{ctx: ctx, path: path, poll: poll, f: f}
+	if !fromStart {
+		if fl.offset, err = seekLastLine(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return fl, nil
+}
+
+// seekLastLine seek file to the start of incomplete last line (or to the end, if last line is complete) and return offset
+func seekLastLine(f *os.File) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 4096)
+	end := fi.Size()
+	for end > 0 {
+		start := end - int64(len(buf))
+		if start < 0 {
+			start = 0
+		}
+		n, err := f.ReadAt(buf[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			end = start + int64(i) + 1
+			break
+		}
+		end = start
+	}
+	return f.Seek(end, io.SeekStart)
+}
+
+func (fl *Follower) Read(p []byte) (int, error) {
+	for {
+		n, err := fl.f.Read(p)
+		if n > 0 {
+			fl.offset += int64(n)
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if reopened, err := fl.checkRotate(); err != nil {
+			return 0, err
+		} else if reopened {
+			continue
+		}
+		select {
+		case <-fl.ctx.Done():
+			return 0, fl.ctx.Err()
+		case <-time.After(fl.poll):
+		}
+	}
+}
+
+// checkRotate check for file rotation on end of file, return true if file is reopened or rewound
+func (fl *Follower) checkRotate() (bool, error) {
+	fi, err := os.Stat(fl.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// renamed, but new file not created yet
+			return false, nil
+		}
+		return false, err
+	}
+	cur, err := fl.f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if !os.SameFile(fi, cur) {
+		if !fl.renamed {
+			// lines can be written to old file before rename
+			fl.renamed = true
+			return true, nil
+		}
+		f, err := os.Open(fl.path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		fl.f.Close()
+		fl.f = f
+		fl.offset = 0
+		fl.renamed = false
+		return true, nil
+	}
+	if fi.Size() < fl.offset {
+		// truncated
+		if _, err = fl.f.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		fl.offset = 0
+		return true, nil
+	}
+	return false, nil
+}
+
+// Offset return read offset in current file
+func (fl *Follower) Offset() int64 {
+	return fl.offset
+}
+
+func (fl *Follower) Close() error {
+	return fl.f.Close()
+}
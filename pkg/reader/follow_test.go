@@ -0,0 +1,133 @@
+package reader
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func appendFile(t *testing.T, path, content string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollower(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	appendFile(t, path, "line 1\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fl, err := NewFollower(ctx, path, 10*time.Millisecond, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fl.Close()
+
+	lines := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(fl)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		errs <- scanner.Err()
+		close(lines)
+	}()
+
+	wantLine := func(want string) {
+		t.Helper()
+		select {
+		case line := <-lines:
+			if line != want {
+				t.Fatalf("Follower read %q, want %q", line, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Follower read timeout, want %q", want)
+		}
+	}
+
+	wantLine("line 1")
+
+	// append
+	appendFile(t, path, "line 2\nline")
+	wantLine("line 2")
+	appendFile(t, path, " 3\n")
+	wantLine("line 3")
+
+	// copytruncate
+	if err = os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, "line 4\n")
+	wantLine("line 4")
+
+	// rename
+	if err = os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path+".1", "line 5\n")
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, "line 6\n")
+	wantLine("line 5")
+	wantLine("line 6")
+
+	cancel()
+	select {
+	case err = <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Follower error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Follower not stopped")
+	}
+}
+
+func TestFollower_fromEnd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	appendFile(t, path, "line 1\nline")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fl, err := NewFollower(ctx, path, 10*time.Millisecond, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fl.Close()
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(fl)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	// pre-existing complete line is skipped, incomplete last line is read
+	appendFile(t, path, " 2\nline 3\n")
+	for _, want := range []string{"line 2", "line 3"} {
+		select {
+		case line := <-lines:
+			if line != want {
+				t.Fatalf("Follower read %q, want %q", line, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Follower read timeout, want %q", want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package reader
+
+import (
+	"sort"
+
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+// Clock return the last log timestamp (unix nano) of completed or in-flight requests, safe for call concurrently with Next
+func (r *Reader) Clock() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clock := r.clock
+	r.rangeQueries(func(s *stat.Stat) {
+		if clock < s.TimeStamp {
+			clock = s.TimeStamp
+		}
+	})
+	return clock
+}
+
+// InFlight return copies of in-flight (not completed) requests, pending at now (unix nano) for minAge nanoseconds or longer,
+// ordered by start time. Safe for call concurrently with Next.
+func (r *Reader) InFlight(now, minAge int64) []*stat.Stat {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var stats []*stat.Stat
+	r.rangeQueries(func(s *stat.Stat) {
+		if s.Pending(now) >= minAge {
+			stats = append(stats, s.Clone())
+		}
+	})
+	sortByStart(stats)
+	return stats
+}
+
+// Orphans return leaked requests (not completed in processor LeakTimeout) since last call, ordered by start time.
+// Leaked requests are collected only with Config.Orphans. Safe for call concurrently with Next.
+func (r *Reader) Orphans() []*stat.Stat {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.orphans
+	r.orphans = nil
+	sortByStart(stats)
+	return stats
+}
+
+func (r *Reader) rangeQueries(f func(s *stat.Stat)) {
+	if r.shards != nil {
+		for _, sh := range r.shards {
+			for _, s := range sh.queries {
+				f(s)
+			}
+		}
+	} else {
+		for _, s := range r.queries {
+			f(s)
+		}
+	}
+}
+
+func sortByStart(stats []*stat.Stat) {
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].StartTimeStamp() == stats[j].StartTimeStamp() {
+			return stats[i].Key() < stats[j].Key()
+		}
+		return stats[i].StartTimeStamp() < stats[j].StartTimeStamp()
+	})
+}
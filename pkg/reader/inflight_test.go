@@ -0,0 +1,106 @@
+package reader
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+var orphansLog = []string{
+	`{"level":"INFO","timestamp":"2023-01-21T13:05:43.290+0500","logger":"render.pb3parser","message":"pb3_target","request_id":"1","from":1674288223,"until":1674288343,"target":"test.a"}`,
+	`{"level":"INFO","timestamp":"2023-01-21T13:05:43.510+0500","logger":"render","message":"query","request_id":"1","query":"SELECT Path FROM graphite_indexd WHERE ((Level=8) AND (Path IN ('test.a'))) AND (Date >='2023-01-21' AND Date <= '2023-01-21') GROUP BY Path FORMAT TabSeparatedRaw","read_rows":"241436","read_bytes":"31416887","query_id":"1::1390f060ca3d959d","time":0.219432977}`,
+	`{"level":"INFO","timestamp":"2023-01-21T13:05:53.000+0500","logger":"render.pb3parser","message":"pb3_target","request_id":"2","from":1674288233,"until":1674288353,"target":"test.b"}`,
+	// request id 1 reused after leak timeout
+	`{"level":"INFO","timestamp":"2023-01-21T13:10:43.773+0500","logger":"http","message":"access","request_id":"1","time":0.1,"wait_slot":0,"wait_fail":false,"url":"/render/?format=carbonapi_v3_pb","status":200}`,
+}
+
+func TestReader_Orphans(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		r := New(strings.NewReader(strings.Join(orphansLog, "\n")), Config{Orphans: true, Workers: workers})
+		var completed []*stat.Stat
+		for r.Next(context.Background()) {
+			completed = append(completed, r.Stat())
+		}
+		if err := r.Err(); err != nil {
+			t.Fatalf("workers %d: Reader.Err() = %v", workers, err)
+		}
+		if len(completed) != 1 || completed[0].Start != 1674288643773000000 || completed[0].IndexReadRows != 0 {
+			t.Errorf("workers %d: completed = %+v", workers, completed)
+		}
+
+		orphans := r.Orphans()
+		if len(orphans) != 1 || orphans[0].Id != "1" || orphans[0].Start != 1674288343290000000 || orphans[0].IndexReadRows != 241436 {
+			t.Errorf("workers %d: Reader.Orphans() = %+v", workers, orphans)
+		}
+		if orphans = r.Orphans(); len(orphans) != 0 {
+			t.Errorf("workers %d: Reader.Orphans() must be reset, got %+v", workers, orphans)
+		}
+
+		clock := r.Clock()
+		if clock != 1674288643773000000 {
+			t.Errorf("workers %d: Reader.Clock() = %d", workers, clock)
+		}
+		inFlight := r.InFlight(clock, int64(time.Minute))
+		if len(inFlight) != 1 || inFlight[0].Id != "2" || inFlight[0].Pending(clock) != 290773000000 {
+			t.Errorf("workers %d: Reader.InFlight() = %+v", workers, inFlight)
+		}
+		if inFlight = r.InFlight(clock, int64(time.Hour)); len(inFlight) != 0 {
+			t.Errorf("workers %d: Reader.InFlight(1h) = %+v", workers, inFlight)
+		}
+	}
+}
+
+func TestReader_LeakTimeout(t *testing.T) {
+	p := stat.NewProcessor()
+	p.LeakTimeout = 10 * time.Minute
+	r := New(strings.NewReader(strings.Join(orphansLog, "\n")), Config{Orphans: true, Processor: p})
+	var completed []*stat.Stat
+	for r.Next(context.Background()) {
+		completed = append(completed, r.Stat())
+	}
+	if len(completed) != 1 || completed[0].Start != 1674288343290000000 || completed[0].IndexReadRows != 241436 {
+		t.Errorf("completed = %+v", completed)
+	}
+	if orphans := r.Orphans(); len(orphans) != 0 {
+		t.Errorf("Reader.Orphans() = %+v", orphans)
+	}
+}
+
+func TestReader_InFlightConcurrent(t *testing.T) {
+	in := generateLog(20000)
+	for _, workers := range []int{1, 4} {
+		r := New(strings.NewReader(in), Config{Workers: workers})
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					for _, s := range r.InFlight(r.Clock(), 0) {
+						_ = s.Pending(r.Clock())
+					}
+				}
+			}
+		}()
+		n := 0
+		for r.Next(context.Background()) {
+			n++
+		}
+		close(done)
+		wg.Wait()
+		if err := r.Err(); err != nil {
+			t.Fatalf("workers %d: Reader.Err() = %v", workers, err)
+		}
+		if n == 0 {
+			t.Errorf("workers %d: no completed requests", workers)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package reader
+
+import "os"
+
+// inode is not available, rotation is detected only by file size
+func inode(fi os.FileInfo) uint64 {
+	return 0
+}
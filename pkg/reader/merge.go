@@ -0,0 +1,204 @@
+package reader
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+var timeStampKey = []byte(`"timestamp":`)
+
+// peekTimeStamp extract timestamp (string or epoch number) from json or console log line without full decode
+func peekTimeStamp(line []byte, timeLayout *stat.TimeLayout) (int64, bool) {
+	if v, ok := stat.ConsoleTimeStamp(line); ok {
+		ts, err := timeLayout.Parse(string(v))
+		return ts, err == nil
+	}
+	start := bytes.Index(line, timeStampKey)
+	if start == -1 {
+		return 0, false
+	}
+	line = bytes.TrimLeft(line[start+len(timeStampKey):], " ")
+	var end int
+	if len(line) > 0 && line[0] == '"' {
+		line = line[1:]
+		end = bytes.IndexByte(line, '"')
+	} else {
+		end = bytes.IndexAny(line, ",}")
+	}
+	if end == -1 {
+		return 0, false
+	}
+	ts, err := timeLayout.Parse(string(bytes.TrimSpace(line[:end])))
+	if err != nil {
+		return 0, false
+	}
+	return ts, true
+}
+
+type mergeInput struct {
+	n         int // input number, for stable order on equal timestamps
+	scanner   lineSource
+	timeStamp int64
+}
+
+type mergeHeap []*mergeInput
+
+func (h mergeHeap) Len() int { return len(h) }
+
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].timeStamp == h[j].timeStamp {
+		return h[i].n < h[j].n
+	}
+	return h[i].timeStamp < h[j].timeStamp
+}
+
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap) Push(x interface{}) {
+	*h = append(*h, x.(*mergeInput))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*h = old[0 : n-1]
+	return x
+}
+
+// mergeSource merge lines from several logs, ordered by timestamp
+type mergeSource struct {
+	timeLayout *stat.TimeLayout
+	inputs     []*mergeInput
+	heap       mergeHeap
+	current    *mergeInput
+	started    bool
+	err        error
+}
+
+func newMergeSource(ins []io.Reader, cfg Config) *mergeSource {
+	m := &mergeSource{
+		timeLayout: cfg.Processor.TimeLayout,
+		inputs:     make([]*mergeInput, len(ins)),
+		heap:       make(mergeHeap, 0, len(ins)),
+	}
+	for i, in := range ins {
+		m.inputs[i] = &mergeInput{n: i, scanner: newSource(in, i, cfg)}
+	}
+	return m
+}
+
+// scan read next line from input and push it to heap (lines without timestamp are keep position in input)
+func (m *mergeSource) scan(in *mergeInput) {
+	if in.scanner.Scan() {
+		if ts, ok := peekTimeStamp(in.scanner.Bytes(), m.timeLayout); ok {
+			in.timeStamp = ts
+		}
+		heap.Push(&m.heap, in)
+	} else if err := in.scanner.Err(); err != nil && m.err == nil {
+		m.err = err
+	}
+}
+
+func (m *mergeSource) Scan() bool {
+	if !m.started {
+		m.started = true
+		for _, in := range m.inputs {
+			m.scan(in)
+		}
+	} else if m.current != nil {
+		m.scan(m.current)
+	}
+	if m.err != nil || len(m.heap) == 0 {
+		m.current = nil
+		return false
+	}
+	m.current = heap.Pop(&m.heap).(*mergeInput)
+	return true
+}
+
+func (m *mergeSource) Bytes() []byte {
+	return m.current.scanner.Bytes()
+}
+
+func (m *mergeSource) Err() error {
+	return m.err
+}
+
+func (m *mergeSource) Pos() LinePos {
+	return m.current.scanner.Pos()
+}
+
+func (m *mergeSource) LineErr() error {
+	return m.current.scanner.LineErr()
+}
+
+func (m *mergeSource) Instance() string {
+	return m.current.scanner.Instance()
+}
+
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// ExpandPaths expand globs and directories (all files in directory) to sorted files list
+func ExpandPaths(paths []string) ([]string, error) {
+	files := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if hasGlobMeta(path) {
+			matches, err := filepath.Glob(path)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("%s: no files matched", path)
+			}
+			sort.Strings(matches)
+			files = append(files, matches...)
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if fi.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				if entry.Type().IsRegular() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		} else {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// OpenFiles open log files (with transparent decompression)
+func OpenFiles(paths []string) ([]io.ReadCloser, error) {
+	ins := make([]io.ReadCloser, 0, len(paths))
+	for _, path := range paths {
+		in, err := Open(path)
+		if err != nil {
+			for _, in := range ins {
+				in.Close()
+			}
+			return nil, err
+		}
+		ins = append(ins, in)
+	}
+	return ins, nil
+}
@@ -0,0 +1,196 @@
+package reader
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+func TestPeekTimeStamp(t *testing.T) {
+	tests := []struct {
+		line   string
+		want   int64
+		wantOk bool
+	}{
+		{line: testLog[0], want: 1674288380528000000, wantOk: true},
+		{line: `{"level":"INFO","timestamp":"2023-01-21T13:06:25.761+0500"}`, want: 1674288385761000000, wantOk: true},
+		{line: `not a json`},
+		{line: `{"level":"INFO","timestamp":"2023-01-21 13:06:25"}`},
+		{line: `{"level":"INFO","timestamp":"2023-01-21T13:06:25.761+0500`},
+		{line: `{"level":"INFO","timestamp":1674288385.761,"logger":"http"}`, want: 1674288385761000000, wantOk: true},
+		{line: `{"level":"INFO","timestamp": 1674288385761}`, want: 1674288385761000000, wantOk: true},
+		{line: `{"level":"INFO","timestamp":"2023-01-21T08:06:25.761Z"}`, want: 1674288385761000000, wantOk: true},
+		{line: "2023-01-21T13:06:25.761+0500\tINFO\thttp\taccess\t{\"request_id\":\"c9ec01a8b31079bfdfbc530a845f279c\"}", want: 1674288385761000000, wantOk: true},
+		{line: "2023-01-21 13:06:25\tINFO\tstarted"},
+	}
+	timeLayout, err := stat.NewTimeLayout("auto", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, ok := peekTimeStamp([]byte(tt.line), timeLayout)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("peekTimeStamp() = (%d, %v), want (%d, %v)", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestNewMerge(t *testing.T) {
+	// request fd3e9fd09a92bc3b7fb0d597f901e953 is splitted between logs
+	ins := []io.Reader{
+		strings.NewReader(strings.Join([]string{testLog[0], testLog[1], testLog[4]}, "\n")),
+		strings.NewReader(strings.Join([]string{testLog[2], testLog[3], testLog[5], testLog[6], testLog[7]}, "\n")),
+	}
+	wantIds := []string{"fd3e9fd09a92bc3b7fb0d597f901e953", "c9ec01a8b31079bfdfbc530a845f279c"}
+	wantCounters := Counters{Lines: 8, Skipped: 2, Bad: 1, Completed: 2}
+
+	r := NewMerge(ins, Config{})
+	ids := make([]string, 0, len(wantIds))
+	for r.Next(context.Background()) {
+		ids = append(ids, r.Stat().Id)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("Reader.Err() = %v", err)
+	}
+	if !reflect.DeepEqual(ids, wantIds) {
+		t.Errorf("Reader.Next() ids = %v, want %v", ids, wantIds)
+	}
+	if r.Counters() != wantCounters {
+		t.Errorf("Reader.Counters() = %+v, want %+v", r.Counters(), wantCounters)
+	}
+	if _, ok := r.Queries()["d4b7d5686f514502c362bafa608ca91b"]; !ok || len(r.Queries()) != 1 {
+		t.Errorf("Reader.Queries() = %v, want only d4b7d5686f514502c362bafa608ca91b", r.Queries())
+	}
+}
+
+func TestNewMerge_Parallel(t *testing.T) {
+	in := generateLog(2000)
+	lines := strings.Split(strings.TrimSuffix(in, "\n"), "\n")
+	// round-robin split, like several nodes logs
+	parts := make([][]string, 3)
+	for i, line := range lines {
+		parts[i%3] = append(parts[i%3], line)
+	}
+	newIns := func() []io.Reader {
+		ins := make([]io.Reader, len(parts))
+		for i := range parts {
+			ins[i] = strings.NewReader(strings.Join(parts[i], "\n"))
+		}
+		return ins
+	}
+
+	want, wantCounters, _ := readAll(t, in, Config{})
+
+	for _, workers := range []int{1, 4} {
+		r := NewMerge(newIns(), Config{Workers: workers})
+		n := 0
+		for r.Next(context.Background()) {
+			n++
+		}
+		if err := r.Err(); err != nil {
+			t.Fatalf("workers %d: Reader.Err() = %v", workers, err)
+		}
+		if n != len(want) {
+			t.Errorf("workers %d: Reader.Next() count = %d, want %d", workers, n, len(want))
+		}
+		if r.Counters() != wantCounters {
+			t.Errorf("workers %d: Reader.Counters() = %+v, want %+v", workers, r.Counters(), wantCounters)
+		}
+	}
+}
+
+func TestExpandPaths(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "logs")
+	if err := os.Mkdir(logDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		filepath.Join(dir, "a.log"), filepath.Join(dir, "a.log.1.gz"), filepath.Join(dir, "b.txt"),
+		filepath.Join(logDir, "c.log"), filepath.Join(logDir, "d.log"),
+	} {
+		if err := os.WriteFile(name, []byte(testContent), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		paths   []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "file",
+			paths: []string{filepath.Join(dir, "b.txt")},
+			want:  []string{filepath.Join(dir, "b.txt")},
+		},
+		{
+			name:  "glob",
+			paths: []string{filepath.Join(dir, "a.log*")},
+			want:  []string{filepath.Join(dir, "a.log"), filepath.Join(dir, "a.log.1.gz")},
+		},
+		{
+			name:  "dir",
+			paths: []string{logDir, filepath.Join(dir, "b.txt")},
+			want:  []string{filepath.Join(logDir, "c.log"), filepath.Join(logDir, "d.log"), filepath.Join(dir, "b.txt")},
+		},
+		{
+			name:    "glob not matched",
+			paths:   []string{filepath.Join(dir, "*.json")},
+			wantErr: true,
+		},
+		{
+			name:    "not exist",
+			paths:   []string{filepath.Join(dir, "e.log")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandPaths(tt.paths)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandPaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandPaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMerge_Instances(t *testing.T) {
+	// the same request ids in logs from several instances
+	in := strings.Join(testLog, "\n")
+	for _, workers := range []int{1, 4} {
+		r := NewMerge([]io.Reader{strings.NewReader(in), strings.NewReader(in)}, Config{Instances: []string{"host1", "host2"}, Workers: workers})
+		var keys []string
+		for r.Next(context.Background()) {
+			keys = append(keys, r.Stat().Key())
+		}
+		if err := r.Err(); err != nil {
+			t.Fatalf("workers %d: Reader.Err() = %v", workers, err)
+		}
+		sort.Strings(keys)
+		wantKeys := []string{
+			"host1/c9ec01a8b31079bfdfbc530a845f279c", "host1/fd3e9fd09a92bc3b7fb0d597f901e953",
+			"host2/c9ec01a8b31079bfdfbc530a845f279c", "host2/fd3e9fd09a92bc3b7fb0d597f901e953",
+		}
+		if !reflect.DeepEqual(keys, wantKeys) {
+			t.Errorf("workers %d: Reader stat keys = %v, want %v", workers, keys, wantKeys)
+		}
+		queries := r.Queries()
+		if len(queries) != 2 || queries["host1/d4b7d5686f514502c362bafa608ca91b"] == nil || queries["host2/d4b7d5686f514502c362bafa608ca91b"] == nil {
+			t.Errorf("workers %d: Reader.Queries() = %v", workers, queries)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package reader
+
+import (
+	"sync"
+
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+const batchSize = 4096
+
+// shard process entries with the same request id hash, so request entries are processed in order
+type shard struct {
+	queries   map[string]*stat.Stat
+	completed []completedStat
+	orphaned  func(s *stat.Stat)
+	orphans   []*stat.Stat
+}
+
+type completedStat struct {
+	n int // entry number in batch
+	s *stat.Stat
+}
+
+// batch is a lines block, decoded in parallel and processed by shards
+type batch struct {
+	buf    []byte
+	ends   []int
+	pos    []LinePos
+	inst   []string // instance label of line
+	shards []int    // shard number of entry, -1 for skipped
+	errs   []error  // line, decode or process error of entry
+	entry  []stat.Entry
+	stats  []*stat.Stat
+}
+
+func shardOf(id string, n int) int {
+	// FNV-1a
+	h := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= 16777619
+	}
+	return int(h % uint32(n))
+}
+
+func (b *batch) line(i int) []byte {
+	start := 0
+	if i > 0 {
+		start = b.ends[i-1]
+	}
+	return b.buf[start:b.ends[i]]
+}
+
+func (r *Reader) readBatch() {
+	b := &r.batch
+	b.buf = b.buf[:0]
+	b.ends = b.ends[:0]
+	b.pos = b.pos[:0]
+	b.errs = b.errs[:0]
+	b.inst = b.inst[:0]
+	for len(b.ends) < batchSize && r.scanner.Scan() {
+		r.counters.Lines++
+		err := r.scanner.LineErr()
+		if err == nil {
+			b.buf = append(b.buf, r.scanner.Bytes()...)
+		}
+		b.ends = append(b.ends, len(b.buf))
+		b.pos = append(b.pos, r.scanner.Pos())
+		b.errs = append(b.errs, err)
+		b.inst = append(b.inst, r.scanner.Instance())
+	}
+	if len(b.ends) < batchSize {
+		r.eof = true
+		r.err = r.scanner.Err()
+	}
+}
+
+func (r *Reader) processBatch() {
+	b := &r.batch
+	n := len(b.ends)
+	if cap(b.entry) < n {
+		b.entry = make([]stat.Entry, batchSize)
+		b.shards = make([]int, batchSize)
+		b.stats = make([]*stat.Stat, batchSize)
+	}
+	workers := len(r.shards)
+
+	// decode
+	var wg sync.WaitGroup
+	chunk := (n + workers - 1) / workers
+	for w := 0; w < workers; w++ {
+		start := w * chunk
+		end := start + chunk
+		if end > n {
+			end = n
+		}
+		if start >= end {
+			break
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				e := &b.entry[i]
+				if b.errs[i] != nil {
+					// too long line
+					b.shards[i] = -1
+				} else if err := r.processor.Unmarshal(e, b.line(i)); err != nil {
+					b.shards[i] = -1
+					b.errs[i] = err
+				} else if e.RequestId == "" {
+					b.shards[i] = -1
+				} else {
+					if e.Instance == "" {
+						e.Instance = b.inst[i]
+					}
+					b.shards[i] = shardOf(e.RequestId, workers)
+					// invalid entry is passed to entry callback, but not processed
+					b.errs[i] = r.validate(e)
+				}
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	// reading is stopped on bad line in strict mode (or on unexpected error), so like in sequential read,
+	// entries after it must not be processed (process errors are validated on decode in strict mode).
+	for i := 0; i < n; i++ {
+		if err := b.errs[i]; err != nil {
+			if _, ok := err.(*stat.ParseError); r.strict || !ok {
+				r.counters.Lines -= int64(n - i - 1)
+				n = i + 1
+				break
+			}
+		}
+	}
+
+	if r.entryFunc != nil {
+		for i := 0; i < n; i++ {
+			if b.shards[i] != -1 {
+				r.entryFunc(b.pos[i], &b.entry[i], b.line(i))
+			}
+		}
+	}
+
+	// process
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			sh := r.shards[w]
+			sh.completed = sh.completed[:0]
+			for i := 0; i < n; i++ {
+				if b.shards[i] != w || b.errs[i] != nil {
+					continue
+				}
+				id, err := r.processor.EntryProcessOrphans(&b.entry[i], sh.queries, sh.orphaned)
+				if err == nil && r.lines {
+					appendLine(sh.queries, &b.entry[i], b.line(i))
+				}
+				if err != nil {
+					b.errs[i] = err
+				} else if id != "" {
+					sh.completed = append(sh.completed, completedStat{n: i, s: sh.queries[id]})
+					delete(sh.queries, id)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// merge completed requests in log order
+	for i := 0; i < n; i++ {
+		b.stats[i] = nil
+	}
+	for _, sh := range r.shards {
+		for _, c := range sh.completed {
+			b.stats[c.n] = c.s
+		}
+		if len(sh.orphans) > 0 {
+			r.orphans = append(r.orphans, sh.orphans...)
+			sh.orphans = sh.orphans[:0]
+		}
+	}
+	r.pending = r.pending[:0]
+	r.pendingPos = 0
+	for i := 0; i < n; i++ {
+		if err := b.errs[i]; err != nil {
+			if r.badLine(b.pos[i], err) {
+				// completed before bad line requests are returned before error
+				for ; i < n; i++ {
+					b.stats[i] = nil
+				}
+				break
+			}
+		} else if b.shards[i] == -1 {
+			r.counters.Skipped++
+		}
+		if s := b.stats[i]; s != nil {
+			b.stats[i] = nil
+			if r.filter(s) {
+				r.pending = append(r.pending, s)
+			}
+		}
+	}
+}
+
+// validate check entry for process errors before sharding in strict mode (in other modes bad entries are skipped by shards)
+func (r *Reader) validate(e *stat.Entry) error {
+	if !r.strict {
+		return nil
+	}
+	return r.processor.Validate(e)
+}
+
+func (r *Reader) mergeQueries() map[string]*stat.Stat {
+	queries := make(map[string]*stat.Stat)
+	for _, sh := range r.shards {
+		for id, s := range sh.queries {
+			queries[id] = s
+		}
+	}
+	return queries
+}
@@ -0,0 +1,361 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+type Counters struct {
+	Lines     int64 // lines read
+	Skipped   int64 // lines skipped (not a request log entry or bad line)
+	Bad       int64 // bad lines (malformed json, timestamp, etc.)
+	Completed int64 // completed requests
+	Filtered  int64 // completed requests out of from/until range or not matched by dimensions
+}
+
+type Config struct {
+	// completed requests, started before From or ended at Until or later, are skipped (unix nano, 0 - no limit)
+	From  int64
+	Until int64
+	// completed requests with other dimensions values are skipped (dimension name - allowed values, see stat.Stat.Dimension)
+	Where map[string][]string
+
+	// parallel workers for decode and process log entries (sharded by request id), <= 1 - sequential read
+	Workers int
+
+	// in-flight (not completed) requests, restored from checkpoint
+	Queries map[string]*stat.Stat
+
+	// stop read on first bad line (by default bad lines are skipped and counted in report)
+	Strict bool
+
+	// maximum line size, longer lines are reported as bad (0 - DefaultMaxLineSize, < 0 - unlimited)
+	MaxLineSize int
+
+	// log entries processor (nil for default)
+	Processor *stat.Processor
+
+	// keep leaked requests (not completed in processor LeakTimeout) for Orphans report, instead of reset
+	Orphans bool
+
+	// log line envelope (auto detected by default)
+	Envelope Envelope
+	// instance labels by input number (like pod name from log path), used if envelope has no host or pod name
+	Instances []string
+
+	// called for every decoded log entry with request id in log order, before process (entry and line are reused after call)
+	Entry EntryFunc
+
+	// keep raw log lines of requests in Stat.Lines
+	Lines bool
+}
+
+// EntryFunc is a log entry callback (for raw lines extract)
+type EntryFunc func(pos LinePos, e *stat.Entry, line []byte)
+
+// maxBadSamples is a maximum number of sample line positions per bad line reason
+const maxBadSamples = 5
+
+// BadLines is a bad lines report for reason
+type BadLines struct {
+	Count   int64
+	Samples []LinePos // first bad lines positions
+}
+
+// LineError is a bad line error, stopped read in strict mode
+type LineError struct {
+	Pos LinePos
+	Err error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("input %d, line %d: %v", e.Pos.Input, e.Pos.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Reader read graphite-clickhouse log and return completed requests stat
+type Reader struct {
+	scanner   lineSource
+	processor *stat.Processor
+	entry     stat.Entry
+	queries   map[string]*stat.Stat
+
+	from   int64
+	until  int64
+	where  map[string][]string
+	strict bool
+
+	// parallel read
+	shards     []*shard
+	batch      batch
+	pending    []*stat.Stat
+	pendingPos int
+	eof        bool
+
+	stat     *stat.Stat
+	err      error
+	counters Counters
+	bad      map[stat.Reason]*BadLines
+
+	// protect in-flight requests for InFlight and Orphans calls, concurrent with Next
+	mu       sync.Mutex
+	clock    int64 // last completed request timestamp
+	orphaned func(s *stat.Stat)
+	orphans  []*stat.Stat
+
+	entryFunc EntryFunc
+	lines     bool
+}
+
+// New return log reader
+func New(in io.Reader, cfg Config) *Reader {
+	return newReader(newSource(in, 0, cfg), cfg)
+}
+
+// NewMerge return reader for several logs (like rotated log files), entries are merged by timestamp
+func NewMerge(ins []io.Reader, cfg Config) *Reader {
+	if len(ins) == 1 {
+		return New(ins[0], cfg)
+	}
+	if cfg.Processor == nil {
+		cfg.Processor = stat.NewProcessor()
+	}
+	return newReader(newMergeSource(ins, cfg), cfg)
+}
+
+// newSource return lines source for input n, unwrapped from envelope
+func newSource(in io.Reader, n int, cfg Config) lineSource {
+	var instance string
+	if n < len(cfg.Instances) {
+		instance = cfg.Instances[n]
+	}
+	return newEnvelopeSource(newLineScanner(in, n, cfg.MaxLineSize), cfg.Envelope, instance, cfg.MaxLineSize)
+}
+
+func newReader(scanner lineSource, cfg Config) *Reader {
+	if cfg.Processor == nil {
+		cfg.Processor = stat.NewProcessor()
+	}
+	r := &Reader{
+		scanner:   scanner,
+		processor: cfg.Processor,
+		from:      cfg.From,
+		until:     cfg.Until,
+		where:     cfg.Where,
+		strict:    cfg.Strict,
+		bad:       make(map[stat.Reason]*BadLines),
+		entryFunc: cfg.Entry,
+		lines:     cfg.Lines,
+	}
+	if cfg.Orphans {
+		r.orphaned = func(s *stat.Stat) {
+			r.orphans = append(r.orphans, s)
+		}
+	}
+	if cfg.Workers > 1 {
+		r.shards = make([]*shard, cfg.Workers)
+		for i := range r.shards {
+			sh := &shard{queries: make(map[string]*stat.Stat)}
+			if cfg.Orphans {
+				sh.orphaned = func(s *stat.Stat) {
+					sh.orphans = append(sh.orphans, s)
+				}
+			}
+			r.shards[i] = sh
+		}
+		for key, s := range cfg.Queries {
+			// entries are sharded by request id
+			r.shards[shardOf(s.Id, len(r.shards))].queries[key] = s
+		}
+	} else if cfg.Queries != nil {
+		r.queries = cfg.Queries
+	} else {
+		r.queries = make(map[string]*stat.Stat)
+	}
+	return r
+}
+
+// badLine count bad line in report, return true if read must be stopped (strict mode or unexpected error)
+func (r *Reader) badLine(pos LinePos, err error) bool {
+	r.counters.Skipped++
+	pErr, ok := err.(*stat.ParseError)
+	if !ok {
+		r.err = &LineError{Pos: pos, Err: err}
+		return true
+	}
+	r.counters.Bad++
+	bad, ok := r.bad[pErr.Reason]
+	if !ok {
+		bad = &BadLines{Samples: make([]LinePos, 0, maxBadSamples)}
+		r.bad[pErr.Reason] = bad
+	}
+	bad.Count++
+	if len(bad.Samples) < maxBadSamples {
+		bad.Samples = append(bad.Samples, pos)
+	}
+	if r.strict {
+		r.err = &LineError{Pos: pos, Err: err}
+		return true
+	}
+	return false
+}
+
+func (r *Reader) filter(s *stat.Stat) bool {
+	r.counters.Completed++
+	if r.clock < s.TimeStamp {
+		r.clock = s.TimeStamp
+	}
+	if (r.from > 0 && s.StartTimeStamp() < r.from) || (r.until > 0 && s.TimeStamp >= r.until) {
+		r.counters.Filtered++
+		return false
+	}
+	for name, values := range r.where {
+		if !matchValue(s.Dimension(name), values) {
+			r.counters.Filtered++
+			return false
+		}
+	}
+	return true
+}
+
+func matchValue(value string, values []string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Next read log until next completed request, return false on end of input, context cancel or read error
+func (r *Reader) Next(ctx context.Context) bool {
+	r.stat = nil
+	if r.shards != nil {
+		return r.nextParallel(ctx)
+	}
+	if r.err != nil {
+		return false
+	}
+	for r.scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			r.err = err
+			return false
+		}
+		r.mu.Lock()
+		s, stop := r.processLine()
+		r.mu.Unlock()
+		if stop {
+			return false
+		}
+		if s != nil {
+			r.stat = s
+			return true
+		}
+	}
+	r.err = r.scanner.Err()
+
+	return false
+}
+
+// appendLine append raw log line to processed entry request stat
+func appendLine(queries map[string]*stat.Stat, e *stat.Entry, line []byte) {
+	if s := queries[stat.QueryKey(e.Instance, e.RequestId)]; s != nil {
+		s.Lines = append(s.Lines, string(line))
+	}
+}
+
+// processLine process scanned line and return completed request stat, stop is true for stop read on bad line
+func (r *Reader) processLine() (s *stat.Stat, stop bool) {
+	r.counters.Lines++
+
+	if err := r.scanner.LineErr(); err != nil {
+		return nil, r.badLine(r.scanner.Pos(), err)
+	}
+	if err := r.processor.Unmarshal(&r.entry, r.scanner.Bytes()); err != nil {
+		return nil, r.badLine(r.scanner.Pos(), err)
+	}
+	if r.entry.RequestId == "" {
+		r.counters.Skipped++
+		return nil, false
+	}
+	if r.entry.Instance == "" {
+		r.entry.Instance = r.scanner.Instance()
+	}
+	if r.entryFunc != nil {
+		r.entryFunc(r.scanner.Pos(), &r.entry, r.scanner.Bytes())
+	}
+
+	id, err := r.processor.EntryProcessOrphans(&r.entry, r.queries, r.orphaned)
+	if err != nil {
+		return nil, r.badLine(r.scanner.Pos(), err)
+	}
+	if r.lines {
+		appendLine(r.queries, &r.entry, r.scanner.Bytes())
+	}
+	if id == "" {
+		return nil, false
+	}
+	s = r.queries[id]
+	delete(r.queries, id)
+
+	if r.filter(s) {
+		return s, false
+	}
+	return nil, false
+}
+
+func (r *Reader) nextParallel(ctx context.Context) bool {
+	for {
+		if r.pendingPos < len(r.pending) {
+			r.stat = r.pending[r.pendingPos]
+			r.pending[r.pendingPos] = nil
+			r.pendingPos++
+			return true
+		}
+		if r.eof || r.err != nil {
+			return false
+		}
+		if err := ctx.Err(); err != nil {
+			r.err = err
+			return false
+		}
+		r.readBatch()
+		r.mu.Lock()
+		r.processBatch()
+		r.mu.Unlock()
+	}
+}
+
+// Stat return completed request stat, read by last Next call
+func (r *Reader) Stat() *stat.Stat {
+	return r.stat
+}
+
+// Err return error (not io.EOF), stopped Next
+func (r *Reader) Err() error {
+	return r.err
+}
+
+func (r *Reader) Counters() Counters {
+	return r.counters
+}
+
+// BadLines return bad lines report by reason
+func (r *Reader) BadLines() map[stat.Reason]*BadLines {
+	return r.bad
+}
+
+// Queries return in-flight (not completed) requests
+func (r *Reader) Queries() map[string]*stat.Stat {
+	if r.shards != nil {
+		return r.mergeQueries()
+	}
+	return r.queries
+}
@@ -0,0 +1,327 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+var testLog = []string{
+	`{"level":"INFO","timestamp":"2023-01-21T13:06:20.528+0500","logger":"metrics-find","message":"query","request_id":"fd3e9fd09a92bc3b7fb0d597f901e953","query":"SELECT Path FROM graphite_indexd WHERE ((Level=20002) AND (Path LIKE 'test.c%')) AND (Date='1970-02-12') GROUP BY Path FORMAT TabSeparatedRaw","read_rows":"413049","read_bytes":"24262486","written_rows":"0","written_bytes":"0","total_rows_to_read":"413049","query_id":"fd3e9fd09a92bc3b7fb0d597f901e953::9c3fc3cb99436f1b","time":0.174105795}`,
+	`{"level":"INFO","timestamp":"2023-01-21T13:06:20.528+0500","logger":"metrics-find","message":"finder","request_id":"fd3e9fd09a92bc3b7fb0d597f901e953","set_cache":"1970-02-12;query=test.c*;ts=1674288000","metrics":6,"find_cached":false,"ttl":600,"query":["test.c*"]}`,
+	`{"level":"INFO","timestamp":"2023-01-21T13:06:25.761+0500","logger":"metrics-find","message":"finder","request_id":"c9ec01a8b31079bfdfbc530a845f279c","get_cache":"1970-02-12;query=test.c*;ts=1674288000","metrics":6,"find_cached":true,"ttl":600,"query":["test.c*"]}`,
+	`{"level":"INFO","timestamp":"2023-01-21T13:06:20.528+0500","logger":"http","message":"access","request_id":"fd3e9fd09a92bc3b7fb0d597f901e953","time":0.174497662,"wait_slot":0,"wait_fail":false,"method":"GET","url":"/metrics/find/?format=carbonapi_v3_pb&query=test.c%2A","peer":"127.0.0.1:39814","client":"","status":200,"find_cached":false}`,
+	`not a json`,
+	`{"level":"INFO","timestamp":"2023-01-21T13:06:20.528+0500","logger":"main","message":"started"}`,
+	`{"level":"INFO","timestamp":"2023-01-21T13:06:25.761+0500","logger":"http","message":"access","request_id":"c9ec01a8b31079bfdfbc530a845f279c","time":0.00016375,"wait_slot":0,"wait_fail":false,"method":"GET","url":"/metrics/find/?format=carbonapi_v3_pb&query=test.c%2A","peer":"127.0.0.1:39818","client":"","status":200,"find_cached":true}`,
+	`{"level":"INFO","timestamp":"2023-01-21T13:07:04.355+0500","logger":"autocomplete","message":"query","request_id":"d4b7d5686f514502c362bafa608ca91b","query":"SELECT splitByChar('=', arrayJoin(Tags))[1] AS value FROM graphite_tagsd  WHERE ((Tag1='app=chproxy') AND (arrayJoin(Tags) LIKE 'c%')) AND (Date >= '2023-01-21' AND Date <= '2023-01-21') GROUP BY value ORDER BY value LIMIT 10001","written_rows":"0","written_bytes":"0","total_rows_to_read":"404694","read_rows":"404694","read_bytes":"160109507","query_id":"d4b7d5686f514502c362bafa608ca91b::4523f47e4368149d","time":0.175910111}`,
+}
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name         string
+		from         int64
+		until        int64
+		wantIds      []string
+		wantCounters Counters
+		wantQueries  []string
+	}{
+		{
+			name:         "all",
+			wantIds:      []string{"fd3e9fd09a92bc3b7fb0d597f901e953", "c9ec01a8b31079bfdfbc530a845f279c"},
+			wantCounters: Counters{Lines: 8, Skipped: 2, Bad: 1, Completed: 2},
+			wantQueries:  []string{"d4b7d5686f514502c362bafa608ca91b"},
+		},
+		{
+			name:         "from",
+			from:         1674288385761000000,
+			wantIds:      []string{"c9ec01a8b31079bfdfbc530a845f279c"},
+			wantCounters: Counters{Lines: 8, Skipped: 2, Bad: 1, Completed: 2, Filtered: 1},
+			wantQueries:  []string{"d4b7d5686f514502c362bafa608ca91b"},
+		},
+		{
+			name:         "until",
+			until:        1674288385761000000,
+			wantIds:      []string{"fd3e9fd09a92bc3b7fb0d597f901e953"},
+			wantCounters: Counters{Lines: 8, Skipped: 2, Bad: 1, Completed: 2, Filtered: 1},
+			wantQueries:  []string{"d4b7d5686f514502c362bafa608ca91b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(strings.NewReader(strings.Join(testLog, "\n")), Config{From: tt.from, Until: tt.until})
+			ids := make([]string, 0, len(tt.wantIds))
+			for r.Next(context.Background()) {
+				ids = append(ids, r.Stat().Id)
+			}
+			if err := r.Err(); err != nil {
+				t.Fatalf("Reader.Err() = %v", err)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIds) {
+				t.Errorf("Reader.Next() ids = %v, want %v", ids, tt.wantIds)
+			}
+			if r.Counters() != tt.wantCounters {
+				t.Errorf("Reader.Counters() = %+v, want %+v", r.Counters(), tt.wantCounters)
+			}
+			queries := make([]string, 0, len(r.Queries()))
+			for id := range r.Queries() {
+				queries = append(queries, id)
+			}
+			if !reflect.DeepEqual(queries, tt.wantQueries) {
+				t.Errorf("Reader.Queries() = %v, want %v", queries, tt.wantQueries)
+			}
+		})
+	}
+}
+
+func TestReader_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := New(strings.NewReader(strings.Join(testLog, "\n")), Config{})
+	if r.Next(ctx) {
+		t.Errorf("Reader.Next() = true after cancel")
+	}
+	if r.Err() != context.Canceled {
+		t.Errorf("Reader.Err() = %v, want %v", r.Err(), context.Canceled)
+	}
+}
+
+// generateLog return log with interleaved render requests (some are not completed)
+func generateLog(n int) string {
+	var sb strings.Builder
+	ts := time.Date(2023, 1, 21, 13, 5, 43, 0, time.FixedZone("", 5*3600))
+	write := func(id string, i int, format string, args ...interface{}) {
+		t := ts.Add(time.Duration(i) * time.Millisecond).Format("2006-01-02T15:04:05.000-0700")
+		fmt.Fprintf(&sb, `{"level":"INFO","timestamp":"%s","request_id":"%s",`, t, id)
+		fmt.Fprintf(&sb, format, args...)
+		sb.WriteString("}\n")
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < 3; j++ {
+			id := fmt.Sprintf("%032d", i-j)
+			switch j {
+			case 0:
+				write(id, i, `"logger":"render.pb3parser","message":"pb3_target","from":1674288223,"until":1674288343,"target":"test.%d"`, i%7)
+			case 1:
+				write(id, i, `"logger":"render","message":"query","query":"SELECT Path, groupArray(Time), groupArray(Value) FROM graphite_reversed PREWHERE Date >= '2023-01-21' AND Date <= '2023-01-21' WHERE (Path in metrics_list) AND (Time >= 1674288230 AND Time <= 1674288349) GROUP BY Path","read_rows":"%d","read_bytes":"%d","time":0.1`, i, i*10)
+			case 2:
+				if i%11 != 0 {
+					write(id, i, `"logger":"http","message":"access","time":0.2,"wait_slot":0,"wait_fail":false,"url":"/render/?format=carbonapi_v3_pb","status":200`)
+				}
+			}
+		}
+		if i%13 == 0 {
+			sb.WriteString("not a json\n")
+		}
+	}
+	return sb.String()
+}
+
+func readAll(t *testing.T, in string, cfg Config) ([]*stat.Stat, Counters, map[string]*stat.Stat) {
+	r := New(strings.NewReader(in), cfg)
+	stats := make([]*stat.Stat, 0, 1024)
+	for r.Next(context.Background()) {
+		stats = append(stats, r.Stat())
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("Reader.Err() = %v", err)
+	}
+	return stats, r.Counters(), r.Queries()
+}
+
+func TestReader_Parallel(t *testing.T) {
+	in := generateLog(3 * batchSize)
+	from := time.Date(2023, 1, 21, 13, 5, 45, 0, time.FixedZone("", 5*3600)).UnixNano()
+	for _, workers := range []int{2, 3, 8} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			wantStats, wantCounters, wantQueries := readAll(t, in, Config{From: from})
+			stats, counters, queries := readAll(t, in, Config{From: from, Workers: workers})
+			if len(wantStats) == 0 {
+				t.Fatal("sequential read return no stats")
+			}
+			if !reflect.DeepEqual(stats, wantStats) {
+				t.Errorf("Reader.Next() stats differ from sequential read: %s", cmp.Diff(wantStats, stats))
+			}
+			if counters != wantCounters {
+				t.Errorf("Reader.Counters() = %+v, want %+v", counters, wantCounters)
+			}
+			if !reflect.DeepEqual(queries, wantQueries) {
+				t.Errorf("Reader.Queries() differ from sequential read: %s", cmp.Diff(wantQueries, queries))
+			}
+		})
+	}
+}
+
+func TestReader_ParallelStrict(t *testing.T) {
+	// decode error (not a json line)
+	testReaderParallelStrict(t, generateLog(batchSize))
+
+	// process error in the middle of batch, entries after it must not be applied by other shards
+	lines := strings.Split(generateLog(batchSize), "\n")
+	valid := lines[:0]
+	for _, line := range lines {
+		if line != "not a json" {
+			valid = append(valid, line)
+		}
+	}
+	bad := len(valid) / 2
+	valid[bad] = strings.Replace(valid[bad], `"timestamp":"`, `"timestamp":"invalid`, 1)
+	t.Run("invalid timestamp", func(t *testing.T) {
+		testReaderParallelStrict(t, strings.Join(valid, "\n"))
+	})
+}
+
+func testReaderParallelStrict(t *testing.T, in string) {
+	read := func(cfg Config) ([]*stat.Stat, []int64, Counters, map[string]*stat.Stat, error) {
+		var lines []int64
+		cfg.Strict = true
+		cfg.Entry = func(pos LinePos, e *stat.Entry, line []byte) {
+			lines = append(lines, pos.Line)
+		}
+		r := New(strings.NewReader(in), cfg)
+		var stats []*stat.Stat
+		for r.Next(context.Background()) {
+			stats = append(stats, r.Stat())
+		}
+		return stats, lines, r.Counters(), r.Queries(), r.Err()
+	}
+	wantStats, wantLines, wantCounters, wantQueries, wantErr := read(Config{})
+	if wantErr == nil {
+		t.Fatal("sequential read must fail")
+	}
+	for _, workers := range []int{2, 3} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			stats, lines, counters, queries, err := read(Config{Workers: workers})
+			if !reflect.DeepEqual(err, wantErr) {
+				t.Errorf("Reader.Err() = %v, want %v", err, wantErr)
+			}
+			if !reflect.DeepEqual(stats, wantStats) {
+				t.Errorf("Reader.Next() stats differ from sequential read: %s", cmp.Diff(wantStats, stats))
+			}
+			if !reflect.DeepEqual(lines, wantLines) {
+				t.Errorf("Config.Entry() lines = %v, want %v", lines, wantLines)
+			}
+			if counters != wantCounters {
+				t.Errorf("Reader.Counters() = %+v, want %+v", counters, wantCounters)
+			}
+			if !reflect.DeepEqual(queries, wantQueries) {
+				t.Errorf("Reader.Queries() differ from sequential read: %s", cmp.Diff(wantQueries, queries))
+			}
+		})
+	}
+}
+
+func TestReader_Entry(t *testing.T) {
+	for _, workers := range []int{1, 2} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			var (
+				lines []int64
+				raw   []string
+			)
+			cfg := Config{
+				Workers: workers,
+				Entry: func(pos LinePos, e *stat.Entry, line []byte) {
+					if e.RequestId == "fd3e9fd09a92bc3b7fb0d597f901e953" {
+						lines = append(lines, pos.Line)
+						raw = append(raw, string(line))
+					}
+				},
+			}
+			readAll(t, strings.Join(testLog, "\n"), cfg)
+			if want := []int64{1, 2, 4}; !reflect.DeepEqual(lines, want) {
+				t.Errorf("Config.Entry() lines = %v, want %v", lines, want)
+			}
+			if want := []string{testLog[0], testLog[1], testLog[3]}; !reflect.DeepEqual(raw, want) {
+				t.Errorf("Config.Entry() raw lines = %s", cmp.Diff(want, raw))
+			}
+		})
+	}
+}
+
+func TestReader_BadLines(t *testing.T) {
+	lines := append([]string{}, testLog...)
+	lines = append(lines, `{"level":"INFO","timestamp":"2023-01-21 13:07:04","logger":"http","message":"access","request_id":"d4b7d5686f514502c362bafa608ca91b","time":0.2,"url":"/tags/autoComplete/values?expr=app%3Dchproxy&tag=c","status":200}`)
+	in := strings.Join(lines, "\n")
+
+	for _, workers := range []int{1, 2} {
+		t.Run(fmt.Sprintf("lenient workers=%d", workers), func(t *testing.T) {
+			stats, counters, _ := readAll(t, in, Config{Workers: workers})
+			if len(stats) != 2 {
+				t.Errorf("Reader.Next() count = %d, want 2", len(stats))
+			}
+			wantCounters := Counters{Lines: 9, Skipped: 3, Bad: 2, Completed: 2}
+			if counters != wantCounters {
+				t.Errorf("Reader.Counters() = %+v, want %+v", counters, wantCounters)
+			}
+
+			r := New(strings.NewReader(in), Config{Workers: workers})
+			for r.Next(context.Background()) {
+			}
+			wantBad := map[stat.Reason]*BadLines{
+				stat.ReasonInvalidJSON:      {Count: 1, Samples: []LinePos{{Line: 5}}},
+				stat.ReasonInvalidTimeStamp: {Count: 1, Samples: []LinePos{{Line: 9}}},
+			}
+			if !reflect.DeepEqual(r.BadLines(), wantBad) {
+				t.Errorf("Reader.BadLines() = %s", cmp.Diff(wantBad, r.BadLines()))
+			}
+		})
+
+		t.Run(fmt.Sprintf("strict workers=%d", workers), func(t *testing.T) {
+			r := New(strings.NewReader(in), Config{Workers: workers, Strict: true})
+			var ids []string
+			for r.Next(context.Background()) {
+				ids = append(ids, r.Stat().Id)
+			}
+			wantIds := []string{"fd3e9fd09a92bc3b7fb0d597f901e953"}
+			if !reflect.DeepEqual(ids, wantIds) {
+				t.Errorf("Reader.Next() ids = %v, want %v", ids, wantIds)
+			}
+			var lineErr *LineError
+			if !errors.As(r.Err(), &lineErr) {
+				t.Fatalf("Reader.Err() = %v, want *LineError", r.Err())
+			}
+			if lineErr.Pos.Line != 5 {
+				t.Errorf("Reader.Err() line = %d, want 5", lineErr.Pos.Line)
+			}
+			var pErr *stat.ParseError
+			if !errors.As(r.Err(), &pErr) || pErr.Reason != stat.ReasonInvalidJSON {
+				t.Errorf("Reader.Err() = %v, want %s", r.Err(), stat.ReasonInvalidJSON)
+			}
+		})
+	}
+}
+
+func TestReader_Where(t *testing.T) {
+	processor := stat.NewProcessor()
+	processor.Dimensions = []stat.Dimension{{Name: "peer", Source: stat.DimensionField, Key: "peer"}}
+	for _, workers := range []int{1, 2} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			r := New(strings.NewReader(strings.Join(testLog, "\n")), Config{
+				Workers:   workers,
+				Processor: processor,
+				Where:     map[string][]string{stat.DimensionPrefix + "peer": {"127.0.0.1:39818"}},
+			})
+			var ids []string
+			for r.Next(context.Background()) {
+				ids = append(ids, r.Stat().Id)
+			}
+			if err := r.Err(); err != nil {
+				t.Fatalf("Reader.Err() = %v", err)
+			}
+			if want := []string{"c9ec01a8b31079bfdfbc530a845f279c"}; !reflect.DeepEqual(ids, want) {
+				t.Errorf("Reader.Next() ids = %v, want %v", ids, want)
+			}
+			if r.Counters().Filtered != 1 {
+				t.Errorf("Reader.Counters() = %+v, want 1 filtered", r.Counters())
+			}
+		})
+	}
+}
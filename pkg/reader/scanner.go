@@ -0,0 +1,136 @@
+package reader
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+// DefaultMaxLineSize is a default maximum log line size, longer lines are skipped and reported
+const DefaultMaxLineSize = 16 * 1024 * 1024
+
+// lineSource is a log lines stream, like bufio.Scanner
+type lineSource interface {
+	Scan() bool
+	Bytes() []byte
+	Err() error
+	// Pos return position of the last scanned line
+	Pos() LinePos
+	// LineErr return error for the last scanned line (too long line)
+	LineErr() error
+	// Instance return instance label (host or pod name) of the last scanned line
+	Instance() string
+}
+
+// LinePos is a line position in log
+type LinePos struct {
+	Input int   // input number (for several merged logs)
+	Line  int64 // line number, starts from 1
+}
+
+// lineScanner read lines like bufio.Scanner, but without token size limit (lines longer than maxLineSize are truncated and reported)
+type lineScanner struct {
+	r           *bufio.Reader
+	maxLineSize int
+	buf         []byte
+	line        []byte
+	lineErr     error
+	err         error
+	pos         LinePos
+}
+
+func newLineScanner(in io.Reader, n int, maxLineSize int) *lineScanner {
+	if maxLineSize == 0 {
+		maxLineSize = DefaultMaxLineSize
+	}
+	return &lineScanner{r: bufio.NewReaderSize(in, 64*1024), maxLineSize: maxLineSize, pos: LinePos{Input: n}}
+}
+
+func (s *lineScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	s.line = nil
+	s.lineErr = nil
+	var (
+		size    int
+		newLine bool
+	)
+	for {
+		chunk, err := s.r.ReadSlice('\n')
+		if size == 0 && err != bufio.ErrBufferFull {
+			// fast path, line is fit in read buffer
+			s.line = chunk
+		} else {
+			if size == 0 {
+				s.buf = s.buf[:0]
+			}
+			if s.maxLineSize < 0 || len(s.buf)+len(chunk) <= s.maxLineSize {
+				s.buf = append(s.buf, chunk...)
+			} else if len(s.buf) < s.maxLineSize {
+				s.buf = append(s.buf, chunk[:s.maxLineSize-len(s.buf)]...)
+			}
+			s.line = s.buf
+		}
+		size += len(chunk)
+		newLine = len(chunk) > 0 && chunk[len(chunk)-1] == '\n'
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+				return false
+			}
+			if size == 0 {
+				return false
+			}
+		}
+		break
+	}
+
+	s.pos.Line++
+	lineSize := size
+	if newLine {
+		lineSize--
+	}
+	if n := len(s.line); n > 0 && s.line[n-1] == '\n' {
+		s.line = s.line[:n-1]
+	}
+	if n := len(s.line); n > 0 && s.line[n-1] == '\r' {
+		s.line = s.line[:n-1]
+	}
+	if s.maxLineSize > 0 && lineSize > s.maxLineSize {
+		if len(s.line) > s.maxLineSize {
+			s.line = s.line[:s.maxLineSize]
+		}
+		s.lineErr = &stat.ParseError{
+			Reason: stat.ReasonLineTooLong,
+			Msg:    "line size " + strconv.Itoa(lineSize) + " exceeds " + strconv.Itoa(s.maxLineSize),
+		}
+	}
+	return true
+}
+
+func (s *lineScanner) Bytes() []byte {
+	return s.line
+}
+
+// Err return read error (not io.EOF)
+func (s *lineScanner) Err() error {
+	return s.err
+}
+
+func (s *lineScanner) Pos() LinePos {
+	return s.pos
+}
+
+func (s *lineScanner) LineErr() error {
+	return s.lineErr
+}
+
+func (s *lineScanner) Instance() string {
+	return ""
+}
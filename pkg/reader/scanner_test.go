@@ -0,0 +1,120 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/msaf1980/graphite-clickhouse-stat/pkg/stat"
+)
+
+func TestLineScanner(t *testing.T) {
+	long := strings.Repeat("a", 200*1024)
+	tests := []struct {
+		name        string
+		in          string
+		maxLineSize int
+		wantLines   []string
+		wantTooLong []int64 // too long lines numbers
+	}{
+		{
+			name:      "lines",
+			in:        "line 1\r\n\nline 3",
+			wantLines: []string{"line 1", "", "line 3"},
+		},
+		{
+			name:      "long line",
+			in:        "line 1\n" + long + "\nline 3\n",
+			wantLines: []string{"line 1", long, "line 3"},
+		},
+		{
+			name:        "too long line",
+			in:          "line 1\n" + long + "\nline 3\n",
+			maxLineSize: 100 * 1024,
+			wantLines:   []string{"line 1", long[:100*1024], "line 3"},
+			wantTooLong: []int64{2},
+		},
+		{
+			name:        "too long short line",
+			in:          "line 1\nline 22\nline 3",
+			maxLineSize: 6,
+			wantLines:   []string{"line 1", "line 2", "line 3"},
+			wantTooLong: []int64{2},
+		},
+		{
+			name:        "unlimited",
+			in:          "line 1\n" + long + "\nline 3\n",
+			maxLineSize: -1,
+			wantLines:   []string{"line 1", long, "line 3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newLineScanner(strings.NewReader(tt.in), 0, tt.maxLineSize)
+			var (
+				lines   []string
+				tooLong []int64
+			)
+			for s.Scan() {
+				lines = append(lines, string(s.Bytes()))
+				if err := s.LineErr(); err != nil {
+					tooLong = append(tooLong, s.Pos().Line)
+				}
+			}
+			if err := s.Err(); err != nil {
+				t.Fatalf("lineScanner.Err() = %v", err)
+			}
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("lineScanner lines count = %d, want %d", len(lines), len(tt.wantLines))
+			}
+			if !reflect.DeepEqual(tooLong, tt.wantTooLong) {
+				t.Errorf("lineScanner too long lines = %v, want %v", tooLong, tt.wantTooLong)
+			}
+		})
+	}
+}
+
+func TestReader_LongLine(t *testing.T) {
+	// long render query (many targets), must not stop read
+	long := `{"level":"INFO","timestamp":"2023-01-21T13:06:20.528+0500","logger":"render","message":"query","request_id":"fd3e9fd09a92bc3b7fb0d597f901e953","query":"SELECT Path FROM graphite_index WHERE Path IN ('` +
+		strings.Repeat("test.long.metric.path', '", 10000) + `')","read_rows":"1","read_bytes":"1","time":0.1}`
+	lines := append([]string{long}, testLog...)
+	in := strings.Join(lines, "\n")
+
+	for _, workers := range []int{1, 2} {
+		stats, counters, _ := readAll(t, in, Config{Workers: workers})
+		if len(stats) != 2 {
+			t.Errorf("workers %d: Reader.Next() count = %d, want 2", workers, len(stats))
+		}
+		wantCounters := Counters{Lines: 9, Skipped: 2, Bad: 1, Completed: 2}
+		if counters != wantCounters {
+			t.Errorf("workers %d: Reader.Counters() = %+v, want %+v", workers, counters, wantCounters)
+		}
+
+		r := New(strings.NewReader(in), Config{Workers: workers, MaxLineSize: 64 * 1024})
+		n := 0
+		for r.Next(context.Background()) {
+			n++
+		}
+		if err := r.Err(); err != nil {
+			t.Fatalf("workers %d: Reader.Err() = %v", workers, err)
+		}
+		if n != 2 {
+			t.Errorf("workers %d: Reader.Next() count with max line = %d, want 2", workers, n)
+		}
+		wantBad := &BadLines{Count: 1, Samples: []LinePos{{Line: 1}}}
+		if bad := r.BadLines()[stat.ReasonLineTooLong]; !reflect.DeepEqual(bad, wantBad) {
+			t.Errorf("workers %d: Reader.BadLines()[line_too_long] = %+v, want %+v", workers, bad, wantBad)
+		}
+
+		r = New(strings.NewReader(in), Config{Workers: workers, MaxLineSize: 64 * 1024, Strict: true})
+		for r.Next(context.Background()) {
+		}
+		var pErr *stat.ParseError
+		if !errors.As(r.Err(), &pErr) || pErr.Reason != stat.ReasonLineTooLong {
+			t.Errorf("workers %d: strict Reader.Err() = %v, want %s", workers, r.Err(), stat.ReasonLineTooLong)
+		}
+	}
+}
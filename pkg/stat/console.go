@@ -0,0 +1,106 @@
+package stat
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// Format is a log line format
+type Format int8
+
+const (
+	FormatAuto    Format = iota // detect by line: json or zap console
+	FormatJSON                  // zap json encoder
+	FormatConsole               // zap console encoder: timestamp<TAB>LEVEL<TAB>logger<TAB>message<TAB>{json fields}
+)
+
+var formatStrings []string = []string{"auto", "json", "console"}
+
+func (f Format) String() string {
+	return formatStrings[f]
+}
+
+// FormatStrings return supported log formats
+func FormatStrings() []string {
+	return formatStrings
+}
+
+// ParseFormat return log format by name
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return FormatAuto, nil
+	}
+	for i, name := range formatStrings {
+		if s == name {
+			return Format(i), nil
+		}
+	}
+	return FormatAuto, errors.New("unknown log format: " + s)
+}
+
+// UnmarshalConsole reset and decode entry from zap console encoder log line:
+// timestamp<TAB>LEVEL<TAB>[logger<TAB>][caller<TAB>]message[<TAB>{json fields}]
+func (e *Entry) UnmarshalConsole(line []byte) error {
+	e.Reset()
+	fields := bytes.Split(line, []byte{'\t'})
+	if n := len(fields); n > 1 && len(fields[n-1]) > 0 && fields[n-1][0] == '{' {
+		if err := json.Unmarshal(fields[n-1], e); err != nil {
+			return newParseError(ReasonInvalidJSON, err.Error())
+		}
+		fields = fields[:n-1]
+	}
+	if len(fields) < 3 {
+		return newParseError(ReasonInvalidJSON, "not a json or console log line")
+	}
+	e.TimeStamp = RawString(fields[0])
+	e.Level = strings.ToUpper(string(fields[1]))
+	if len(fields) > 3 && !isCaller(fields[2]) {
+		// caller without logger is skipped
+		e.Logger = string(fields[2])
+	}
+	e.Message = string(fields[len(fields)-1])
+	return nil
+}
+
+// isCaller check for zap caller field, like http/access.go:42
+func isCaller(field []byte) bool {
+	end := bytes.LastIndexByte(field, ':')
+	if end == -1 || end == len(field)-1 || !bytes.HasSuffix(field[:end], []byte(".go")) {
+		return false
+	}
+	for _, c := range field[end+1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// UnmarshalFormat reset and decode entry from log line in format
+func (e *Entry) UnmarshalFormat(line []byte, format Format) error {
+	switch format {
+	case FormatJSON:
+		return e.Unmarshal(line)
+	case FormatConsole:
+		return e.UnmarshalConsole(line)
+	}
+	if len(line) == 0 || line[0] == '{' || bytes.IndexByte(line, '\t') == -1 {
+		return e.Unmarshal(line)
+	}
+	return e.UnmarshalConsole(line)
+}
+
+// ConsoleTimeStamp return timestamp field of zap console encoder log line
+func ConsoleTimeStamp(line []byte) ([]byte, bool) {
+	if len(line) == 0 || line[0] == '{' {
+		return nil, false
+	}
+	end := bytes.IndexByte(line, '\t')
+	if end == -1 {
+		return nil, false
+	}
+	return line[:end], true
+}
@@ -0,0 +1,112 @@
+package stat
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/google/go-cmp/cmp"
+)
+
+// toConsole convert json log entry to zap console encoder format
+func toConsole(t *testing.T, entry string) string {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(entry), &m); err != nil {
+		t.Fatalf("%v: %s", err, entry)
+	}
+	line := m["timestamp"].(string) + "\t" + m["level"].(string) + "\t" + m["logger"].(string) + "\t" + m["message"].(string)
+	for _, key := range []string{"timestamp", "level", "logger", "message"} {
+		delete(m, key)
+	}
+	if len(m) > 0 {
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line += "\t" + string(b)
+	}
+	return line
+}
+
+func Test_EntryProcess_console(t *testing.T) {
+	for _, tt := range logEntryProcessTests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := make(map[string]*Stat)
+			var e Entry
+
+			for _, entry := range tt.entries {
+				line := toConsole(t, entry)
+				if err := e.UnmarshalFormat([]byte(line), FormatAuto); err != nil {
+					t.Fatalf("%v: %s", err, line)
+				}
+				if _, err := EntryProcess(&e, queries); err != nil {
+					t.Fatalf("EntryProcess() error = %v: %s", err, line)
+				}
+			}
+
+			if !reflect.DeepEqual(tt.wantQueries, queries) {
+				t.Fatalf("EntryProcess() = %s", cmp.Diff(tt.wantQueries, queries))
+			}
+		})
+	}
+}
+
+func TestEntry_UnmarshalConsole(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    Entry
+		wantErr bool
+	}{
+		{
+			name: "without fields",
+			line: "2023-01-21T13:06:20.528+0500\tinfo\tmain\tstarted",
+			want: Entry{TimeStamp: "2023-01-21T13:06:20.528+0500", Level: "INFO", Logger: "main", Message: "started"},
+		},
+		{
+			name: "without logger",
+			line: "2023-01-21T13:06:20.528+0500\tINFO\tstarted",
+			want: Entry{TimeStamp: "2023-01-21T13:06:20.528+0500", Level: "INFO", Message: "started"},
+		},
+		{
+			name: "with caller",
+			line: "2023-01-21T13:06:20.528+0500\tINFO\thttp\thttp/access.go:42\taccess\t{\"request_id\":\"1\",\"status\":200}",
+			want: Entry{TimeStamp: "2023-01-21T13:06:20.528+0500", Level: "INFO", Logger: "http", Message: "access", RequestId: "1", Status: 200},
+		},
+		{
+			name: "with caller, without logger",
+			line: "2023-01-21T13:06:20.528+0500\tINFO\thttp/access.go:42\taccess\t{\"request_id\":\"1\",\"status\":200}",
+			want: Entry{TimeStamp: "2023-01-21T13:06:20.528+0500", Level: "INFO", Message: "access", RequestId: "1", Status: 200},
+		},
+		{
+			name: "logger like file",
+			line: "2023-01-21T13:06:20.528+0500\tINFO\tconfig.go\tstarted",
+			want: Entry{TimeStamp: "2023-01-21T13:06:20.528+0500", Level: "INFO", Logger: "config.go", Message: "started"},
+		},
+		{
+			name:    "not a log line",
+			line:    "2023-01-21\tstarted",
+			wantErr: true,
+		},
+		{
+			name:    "invalid fields",
+			line:    "2023-01-21T13:06:20.528+0500\tINFO\thttp\taccess\t{\"request_id\":",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e Entry
+			err := e.UnmarshalConsole([]byte(tt.line))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Entry.UnmarshalConsole() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(tt.want, e) {
+				t.Errorf("Entry.UnmarshalConsole() = %s", cmp.Diff(tt.want, e))
+			}
+		})
+	}
+}
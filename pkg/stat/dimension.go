@@ -0,0 +1,156 @@
+package stat
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// DimensionSource is a custom dimension value source
+type DimensionSource int8
+
+const (
+	DimensionHeader DimensionSource = iota // request_headers key
+	DimensionField                         // top-level log entry field
+)
+
+var dimensionSourceStrings []string = []string{"header", "field"}
+
+func (s DimensionSource) String() string {
+	return dimensionSourceStrings[s]
+}
+
+// DimensionPrefix is a custom dimension name prefix in dimensions lists (like group by or filters), for separate from builtin dimensions
+const DimensionPrefix = "dim."
+
+var dimensionStrings []string = []string{"instance", "username", "grafana_org_id", "dashboard_id", "panel_id", "carbonapi_uuid"}
+
+// DimensionStrings return builtin dimensions names
+func DimensionStrings() []string {
+	return dimensionStrings
+}
+
+// Dimension is a custom request dimension, extracted from request header or top-level log entry field
+type Dimension struct {
+	Name   string
+	Source DimensionSource
+	Key    string
+}
+
+func (d Dimension) String() string {
+	return d.Name + "=" + d.Source.String() + ":" + d.Key
+}
+
+// ParseDimension parse custom dimension mapping, like tenant=header:X-Scope-OrgID or api_key=field:api_key
+func ParseDimension(s string) (Dimension, error) {
+	var d Dimension
+	name, source, ok := strings.Cut(s, "=")
+	if !ok {
+		return d, errors.New("invalid dimension " + s + ", must be name=header:key or name=field:key")
+	}
+	d.Name = strings.TrimSpace(name)
+	if d.Name == "" || strings.ContainsAny(d.Name, ",=") {
+		return d, errors.New("invalid dimension name in " + s)
+	}
+	source, key, ok := strings.Cut(source, ":")
+	if !ok || key == "" {
+		return d, errors.New("invalid dimension " + s + ", must be name=header:key or name=field:key")
+	}
+	switch source {
+	case "header":
+		d.Source = DimensionHeader
+	case "field":
+		d.Source = DimensionField
+	default:
+		return d, errors.New("invalid dimension source " + source + ", must be header or field")
+	}
+	d.Key = key
+	return d, nil
+}
+
+// Dimension return request dimension value by name: builtin (like instance) or custom (with DimensionPrefix)
+func (s *Stat) Dimension(name string) string {
+	switch name {
+	case "instance":
+		return s.Instance
+	case "username":
+		return s.Username
+	case "grafana_org_id":
+		return s.GrafanaOrgId
+	case "dashboard_id":
+		return s.DashboardId
+	case "panel_id":
+		return s.PanelId
+	case "carbonapi_uuid":
+		return s.CarbonapiUuid
+	}
+	if strings.HasPrefix(name, DimensionPrefix) {
+		return s.Dimensions[name[len(DimensionPrefix):]]
+	}
+	return ""
+}
+
+// readDimensions set custom dimensions from request headers and entry fields (missed values don't reset dimension)
+func (p *Processor) readDimensions(v *Stat, e *Entry) {
+	for i := range p.Dimensions {
+		d := &p.Dimensions[i]
+		var value string
+		switch d.Source {
+		case DimensionHeader:
+			value = e.RequestHeaders[d.Key]
+		case DimensionField:
+			value = e.Fields[d.Key]
+		}
+		if value == "" {
+			continue
+		}
+		if v.Dimensions == nil {
+			v.Dimensions = make(map[string]string)
+		}
+		v.Dimensions[d.Name] = value
+	}
+}
+
+// hasFieldDimensions return true if custom dimensions extracted from entry fields
+func (p *Processor) hasFieldDimensions() bool {
+	for i := range p.Dimensions {
+		if p.Dimensions[i].Source == DimensionField {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonObject return json object part of json or zap console encoder log line
+func jsonObject(line []byte) []byte {
+	if len(line) > 0 && line[0] == '{' {
+		return line
+	}
+	if n := bytes.LastIndexByte(line, '\t'); n != -1 && n+1 < len(line) && line[n+1] == '{' {
+		return line[n+1:]
+	}
+	return nil
+}
+
+// unmarshalFields decode top-level string or number fields of log line for custom dimensions (other values are empty)
+func (e *Entry) unmarshalFields(line []byte) error {
+	obj := jsonObject(line)
+	if obj == nil {
+		return nil
+	}
+	var fields map[string]RawString
+	if err := json.Unmarshal(obj, &fields); err != nil {
+		return newParseError(ReasonInvalidJSON, err.Error())
+	}
+	if e.Fields == nil {
+		e.Fields = make(map[string]string, len(fields))
+	}
+	for k, v := range fields {
+		if v != "" {
+			e.Fields[k] = string(v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package stat
+
+import (
+	"testing"
+)
+
+func TestParseDimension(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Dimension
+		wantErr bool
+	}{
+		{s: "tenant=header:X-Scope-OrgID", want: Dimension{Name: "tenant", Source: DimensionHeader, Key: "X-Scope-OrgID"}},
+		{s: "api_key=field:api_key", want: Dimension{Name: "api_key", Source: DimensionField, Key: "api_key"}},
+		{s: "tenant", wantErr: true},
+		{s: "=header:X-Scope-OrgID", wantErr: true},
+		{s: "tenant=header", wantErr: true},
+		{s: "tenant=query:tenant", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseDimension(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDimension() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("ParseDimension() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessor_Dimensions(t *testing.T) {
+	p := NewProcessor()
+	p.Dimensions = []Dimension{
+		{Name: "tenant", Source: DimensionHeader, Key: "X-Scope-OrgID"},
+		{Name: "api_key", Source: DimensionField, Key: "api_key"},
+		{Name: "shard", Source: DimensionField, Key: "shard"},
+	}
+	lines := []string{
+		`{"level":"INFO","timestamp":"2023-01-21T13:06:20.428+0500","logger":"render","message":"finder","request_id":"1","metrics":1,"api_key":"key1","request_headers":{"X-Scope-OrgID":"team1"}}`,
+		"2023-01-21T13:06:20.528+0500\tINFO\thttp\taccess\t" + `{"request_id":"1","time":0.1,"url":"/render/?format=json","status":200,"shard":2}`,
+	}
+	queries := make(map[string]*Stat)
+	var (
+		e   Entry
+		key string
+	)
+	for _, line := range lines {
+		if err := p.Unmarshal(&e, []byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		if key, err = p.EntryProcess(&e, queries); err != nil {
+			t.Fatalf("EntryProcess() error = %v", err)
+		}
+	}
+	s := queries[key]
+	if s == nil {
+		t.Fatal("EntryProcess() request not completed")
+	}
+	want := map[string]string{"tenant": "team1", "api_key": "key1", "shard": "2"}
+	for name, v := range want {
+		if got := s.Dimension(DimensionPrefix + name); got != v {
+			t.Errorf("Stat.Dimension(%q) = %q, want %q", name, got, v)
+		}
+	}
+	if len(s.Dimensions) != len(want) {
+		t.Errorf("Stat.Dimensions = %v, want %v", s.Dimensions, want)
+	}
+}
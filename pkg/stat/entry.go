@@ -0,0 +1,257 @@
+package stat
+
+import (
+	"strconv"
+	"unsafe"
+
+	"github.com/goccy/go-json"
+)
+
+func unsafeString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// Int64 is a number, logged as json number or string
+type Int64 int64
+
+func (n *Int64) UnmarshalJSON(b []byte) error {
+	*n = 0
+	if len(b) > 1 && b[0] == '"' && b[len(b)-1] == '"' {
+		if v, err := strconv.ParseInt(unsafeString(b[1:len(b)-1]), 10, 64); err == nil {
+			*n = Int64(v)
+		}
+	} else if v, err := strconv.ParseFloat(unsafeString(b), 64); err == nil {
+		*n = Int64(v)
+	}
+	return nil
+}
+
+// Float64 is a float number, logged as json number or string
+type Float64 float64
+
+func (n *Float64) UnmarshalJSON(b []byte) error {
+	*n = 0
+	if len(b) > 1 && b[0] == '"' && b[len(b)-1] == '"' {
+		b = b[1 : len(b)-1]
+	}
+	if v, err := strconv.ParseFloat(unsafeString(b), 64); err == nil {
+		*n = Float64(v)
+	}
+	return nil
+}
+
+// Bool is a optional bool
+type Bool struct {
+	Set   bool
+	Value bool
+}
+
+func (v *Bool) UnmarshalJSON(b []byte) error {
+	switch string(b) {
+	case "true":
+		*v = Bool{Set: true, Value: true}
+	case "false":
+		*v = Bool{Set: true}
+	default:
+		*v = Bool{}
+	}
+	return nil
+}
+
+// String is a optional string, other json types are ignored (like query list in finder entries)
+type String string
+
+func (s *String) UnmarshalJSON(b []byte) error {
+	*s = ""
+	if len(b) > 1 && b[0] == '"' {
+		var v string
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		*s = String(v)
+	}
+	return nil
+}
+
+// RawString is a string or number (kept as number text), like timestamp (formatted or epoch)
+type RawString string
+
+func (s *RawString) UnmarshalJSON(b []byte) error {
+	*s = ""
+	if len(b) > 1 && b[0] == '"' {
+		var v string
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		*s = RawString(v)
+	} else if len(b) > 0 && (b[0] == '-' || (b[0] >= '0' && b[0] <= '9')) {
+		*s = RawString(b)
+	}
+	return nil
+}
+
+// Exist mark existing key
+type Exist bool
+
+func (e *Exist) UnmarshalJSON(_ []byte) error {
+	*e = true
+	return nil
+}
+
+// Entry is a graphite-clickhouse log entry, only keys used for stat are decoded
+type Entry struct {
+	Level     string    `json:"level"`
+	TimeStamp RawString `json:"timestamp"`
+	Logger    string    `json:"logger"`
+	Message   string    `json:"message"`
+	RequestId string    `json:"request_id"`
+
+	// clickhouse query
+	Query     String  `json:"query"`
+	QueryId   string  `json:"query_id"`
+	ReadRows  Int64   `json:"read_rows"`
+	ReadBytes Int64   `json:"read_bytes"`
+	Time      Float64 `json:"time"`
+	Error     string  `json:"error"`
+
+	// render targets
+	Target string `json:"target"`
+	From   Int64  `json:"from"`
+	Until  Int64  `json:"until"`
+
+	// finder
+	FindCached Bool   `json:"find_cached"`
+	TTL        Exist  `json:"ttl"`
+	Metrics    Int64  `json:"metrics"`
+	GetCache   string `json:"get_cache"`
+	SetCache   string `json:"set_cache"`
+
+	// data_parse
+	ReadPoints Int64   `json:"read_points"`
+	RuntimeNs  Float64 `json:"runtime_ns"` // parse runtime (seconds, despite the name)
+
+	// http access
+	Status   Int64   `json:"status"`
+	WaitSlot Float64 `json:"wait_slot"`
+	WaitFail Bool    `json:"wait_fail"`
+	URL      string  `json:"url"`
+
+	RequestHeaders map[string]string `json:"request_headers"`
+
+	// top-level fields for custom dimensions (decoded only if processor has field dimensions)
+	Fields map[string]string `json:"-"`
+
+	// instance label (host or pod name), decoded from instance field or set by reader from log envelope or input path
+	Instance string `json:"instance"`
+}
+
+// Reset entry for reuse (request headers and fields maps are not reallocated)
+func (e *Entry) Reset() {
+	headers := e.RequestHeaders
+	for k := range headers {
+		delete(headers, k)
+	}
+	fields := e.Fields
+	for k := range fields {
+		delete(fields, k)
+	}
+	*e = Entry{RequestHeaders: headers, Fields: fields}
+}
+
+// Copy return entry copy with own request headers and fields maps (for keep entry, reused by reader)
+func (e *Entry) Copy() Entry {
+	c := *e
+	c.RequestHeaders = copyMap(e.RequestHeaders)
+	c.Fields = copyMap(e.Fields)
+	return c
+}
+
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// Unmarshal reset and decode entry from json log line
+func (e *Entry) Unmarshal(line []byte) error {
+	e.Reset()
+	if err := json.Unmarshal(line, e); err != nil {
+		return newParseError(ReasonInvalidJSON, err.Error())
+	}
+	return nil
+}
+
+// FromMap reset and fill entry from decoded log line
+func (e *Entry) FromMap(logEntry map[string]interface{}) {
+	e.Reset()
+
+	e.Level, _ = readString(logEntry, "level")
+	switch v := logEntry["timestamp"].(type) {
+	case string:
+		e.TimeStamp = RawString(v)
+	case float64:
+		e.TimeStamp = RawString(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	e.Logger, _ = readString(logEntry, "logger")
+	e.Message, _ = readString(logEntry, "message")
+	e.RequestId, _ = readString(logEntry, "request_id")
+	e.Instance, _ = readString(logEntry, "instance")
+
+	if query, err := readString(logEntry, "query"); err == nil {
+		e.Query = String(query)
+	}
+	e.QueryId, _ = readString(logEntry, "query_id")
+	e.ReadRows = readInt64Value(logEntry, "read_rows")
+	e.ReadBytes = readInt64Value(logEntry, "read_bytes")
+	e.Time = readFloat64Value(logEntry, "time")
+	e.Error, _ = readString(logEntry, "error")
+
+	e.Target, _ = readString(logEntry, "target")
+	e.From = readInt64Value(logEntry, "from")
+	e.Until = readInt64Value(logEntry, "until")
+
+	if v, err := readBool(logEntry, "find_cached"); err == nil {
+		e.FindCached = Bool{Set: true, Value: v}
+	}
+	_, ok := logEntry["ttl"]
+	e.TTL = Exist(ok)
+	e.Metrics = readInt64Value(logEntry, "metrics")
+	e.GetCache, _ = readString(logEntry, "get_cache")
+	e.SetCache, _ = readString(logEntry, "set_cache")
+
+	e.ReadPoints = readInt64Value(logEntry, "read_points")
+	e.RuntimeNs = readFloat64Value(logEntry, "runtime_ns")
+
+	e.Status = readInt64Value(logEntry, "status")
+	e.WaitSlot = readFloat64Value(logEntry, "wait_slot")
+	if v, err := readBool(logEntry, "wait_fail"); err == nil {
+		e.WaitFail = Bool{Set: true, Value: v}
+	}
+	e.URL, _ = readString(logEntry, "url")
+
+	if headers, ok := logEntry["request_headers"].(map[string]interface{}); ok {
+		if e.RequestHeaders == nil {
+			e.RequestHeaders = make(map[string]string)
+		}
+		for k, v := range headers {
+			if s, ok := v.(string); ok {
+				e.RequestHeaders[k] = s
+			}
+		}
+	}
+}
+
+func readInt64Value(logEntry map[string]interface{}, key string) Int64 {
+	n, _ := readInt64(logEntry, key)
+	return Int64(n)
+}
+
+func readFloat64Value(logEntry map[string]interface{}, key string) Float64 {
+	n, _ := readFloat64(logEntry, key)
+	return Float64(n)
+}
@@ -0,0 +1,32 @@
+package stat
+
+// Reason is a bad log line reason
+type Reason int8
+
+const (
+	ReasonInvalidJSON Reason = iota
+	ReasonInvalidTimeStamp
+	ReasonInvalidQuery
+	ReasonLineTooLong
+	ReasonInvalidEnvelope
+)
+
+var reasonStrings []string = []string{"invalid_json", "invalid_timestamp", "invalid_query", "line_too_long", "invalid_envelope"}
+
+func (r Reason) String() string {
+	return reasonStrings[r]
+}
+
+// ParseError is a log entry parse error
+type ParseError struct {
+	Reason Reason
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return e.Reason.String() + ": " + e.Msg
+}
+
+func newParseError(reason Reason, msg string) *ParseError {
+	return &ParseError{Reason: reason, Msg: msg}
+}
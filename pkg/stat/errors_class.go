@@ -0,0 +1,136 @@
+package stat
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrorRule is a error classification rule, matched errors are reported as class
+type ErrorRule struct {
+	Regexp *regexp.Regexp
+	Class  string
+}
+
+func (r ErrorRule) String() string {
+	return r.Class + "=" + r.Regexp.String()
+}
+
+// ParseErrorRule parse error classification rule, like readonly=Table is in readonly mode
+func ParseErrorRule(s string) (ErrorRule, error) {
+	var r ErrorRule
+	class, re, ok := strings.Cut(s, "=")
+	if !ok || class == "" || re == "" {
+		return r, errors.New("invalid error rule " + s + ", must be class=regexp")
+	}
+	var err error
+	if r.Regexp, err = regexp.Compile(re); err != nil {
+		return r, errors.New("invalid error rule " + s + ": " + err.Error())
+	}
+	r.Class = class
+	return r, nil
+}
+
+// clickhouseErrors is a symbolic names of ClickHouse error codes, used if name is not logged (old ClickHouse versions)
+var clickhouseErrors = map[int]string{
+	3:   "UNEXPECTED_END_OF_FILE",
+	32:  "ATTEMPT_TO_READ_AFTER_EOF",
+	47:  "UNKNOWN_IDENTIFIER",
+	60:  "UNKNOWN_TABLE",
+	62:  "SYNTAX_ERROR",
+	81:  "UNKNOWN_DATABASE",
+	158: "TOO_MANY_ROWS",
+	159: "TIMEOUT_EXCEEDED",
+	160: "TOO_SLOW",
+	164: "READONLY",
+	173: "CANNOT_ALLOCATE_MEMORY",
+	202: "TOO_MANY_SIMULTANEOUS_QUERIES",
+	203: "NO_FREE_CONNECTION",
+	209: "SOCKET_TIMEOUT",
+	210: "NETWORK_ERROR",
+	241: "MEMORY_LIMIT_EXCEEDED",
+	242: "TABLE_IS_READ_ONLY",
+	252: "TOO_MANY_PARTS",
+	279: "ALL_CONNECTION_TRIES_FAILED",
+	307: "TOO_MANY_BYTES",
+	319: "UNKNOWN_STATUS_OF_INSERT",
+	394: "QUERY_WAS_CANCELLED",
+	396: "TOO_MANY_ROWS_OR_BYTES",
+	497: "ACCESS_DENIED",
+	516: "AUTHENTICATION_FAILED",
+}
+
+// ClickHouseError return ClickHouse exception code and symbolic name from error message, like
+//
+//	Code: 241. DB::Exception: Memory limit (for query) exceeded: ... (MEMORY_LIMIT_EXCEEDED) (version 22.8.5.29 (official build))
+//	Code: 241, e.displayText() = DB::Exception: Memory limit (for query) exceeded: ...
+func ClickHouseError(err string) (code int, name string, ok bool) {
+	start := strings.Index(err, "Code: ")
+	if start == -1 {
+		return 0, "", false
+	}
+	t := err[start+6:]
+	end := 0
+	for end < len(t) && t[end] >= '0' && t[end] <= '9' {
+		end++
+	}
+	code, e := strconv.Atoi(t[:end])
+	if e != nil {
+		return 0, "", false
+	}
+	if name = exceptionName(t[end:]); name == "" {
+		name = clickhouseErrors[code]
+	}
+	return code, name, true
+}
+
+// exceptionName return symbolic error name, logged by new ClickHouse versions in parentheses, like (MEMORY_LIMIT_EXCEEDED)
+func exceptionName(t string) string {
+	for {
+		start := strings.IndexByte(t, '(')
+		if start == -1 {
+			return ""
+		}
+		t = t[start+1:]
+		end := strings.IndexByte(t, ')')
+		if end == -1 {
+			return ""
+		}
+		if isErrorName(t[:end]) {
+			return t[:end]
+		}
+	}
+}
+
+func isErrorName(s string) bool {
+	if len(s) < 3 || strings.IndexByte(s, '_') == -1 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !(c == '_' || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// ErrorClass return error class: by processor error rules, ClickHouse error code (like Code: 241 MEMORY_LIMIT_EXCEEDED),
+// unreachable hosts, known network errors or error message prefix
+func (p *Processor) ErrorClass(err string) string {
+	if err == "" {
+		return ""
+	}
+	for i := range p.ErrorRules {
+		if p.ErrorRules[i].Regexp.MatchString(err) {
+			return p.ErrorRules[i].Class
+		}
+	}
+	if code, name, ok := ClickHouseError(err); ok {
+		if name == "" {
+			return "Code: " + strconv.Itoa(code)
+		}
+		return "Code: " + strconv.Itoa(code) + " " + name
+	}
+	return parseError(err)
+}
@@ -0,0 +1,55 @@
+package stat
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestProcessor_ErrorClass(t *testing.T) {
+	p := NewProcessor()
+	p.ErrorRules = []ErrorRule{{Regexp: regexp.MustCompile(`Table is in readonly mode`), Class: "readonly"}}
+	tests := []struct {
+		err  string
+		want string
+	}{
+		{err: ""},
+		{
+			err:  "clickhouse response status 500: Code: 241. DB::Exception: Memory limit (for query) exceeded: would use 9.32 GiB (attempt to allocate chunk of 4194304 bytes), maximum: 9.31 GiB. (MEMORY_LIMIT_EXCEEDED) (version 22.8.5.29 (official build))",
+			want: "Code: 241 MEMORY_LIMIT_EXCEEDED",
+		},
+		{
+			err:  "clickhouse response status 500: Code: 159, e.displayText() = DB::Exception: Timeout exceeded: elapsed 60.001 seconds, maximum: 60 (version 20.8.3.18)",
+			want: "Code: 159 TIMEOUT_EXCEEDED",
+		},
+		{
+			err:  "clickhouse response status 500: Code: 202. DB::Exception: Too many simultaneous queries. Maximum: 100. (TOO_MANY_SIMULTANEOUS_QUERIES)",
+			want: "Code: 202 TOO_MANY_SIMULTANEOUS_QUERIES",
+		},
+		{err: "clickhouse response status 500: Code: 9999. DB::Exception: Something new", want: "Code: 9999"},
+		{err: "clickhouse response status 500: Code: 242. DB::Exception: Table is in readonly mode", want: "readonly"},
+		{err: `Post "http://127.0.0.1:8123/?query_id=1": dial tcp 127.0.0.1:8123: connect: connection refused`, want: "connection refused"},
+		{err: "clickhouse response status 502: Bad Gateway", want: "clickhouse response "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := p.ErrorClass(tt.err); got != tt.want {
+				t.Errorf("Processor.ErrorClass() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrorRule(t *testing.T) {
+	for _, s := range []string{"readonly", "=readonly", "readonly=", "readonly=("} {
+		if _, err := ParseErrorRule(s); err == nil {
+			t.Errorf("ParseErrorRule(%q) must fail", s)
+		}
+	}
+	r, err := ParseErrorRule("readonly=Table is in readonly mode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Class != "readonly" || !r.Regexp.MatchString("Code: 242. DB::Exception: Table is in readonly mode") {
+		t.Errorf("ParseErrorRule() = %v", r)
+	}
+}
@@ -0,0 +1,41 @@
+package stat
+
+import "time"
+
+// DefaultLeakTimeout is a default timeout for not completed request (request with the same id after timeout is a new request)
+const DefaultLeakTimeout = 240 * time.Second
+
+// Processor process log entries to requests stat
+type Processor struct {
+	// log entry timestamp layout
+	TimeLayout *TimeLayout
+	// log line format
+	Format Format
+	// not completed request timeout (request with the same id after timeout is a new request)
+	LeakTimeout time.Duration
+	// custom request dimensions, extracted from request headers or entry fields
+	Dimensions []Dimension
+	// request type rules, matched by access url path (DefaultTypeRules if nil)
+	TypeRules []TypeRule
+	// error classification rules, checked before builtin rules (ClickHouse error codes, network errors)
+	ErrorRules []ErrorRule
+}
+
+// NewProcessor return processor with default options (auto detected log format and timestamp layout)
+func NewProcessor() *Processor {
+	timeLayout, _ := NewTimeLayout("auto", nil)
+	return &Processor{TimeLayout: timeLayout, LeakTimeout: DefaultLeakTimeout}
+}
+
+var defaultProcessor = NewProcessor()
+
+// Unmarshal reset and decode entry from log line in processor format
+func (p *Processor) Unmarshal(e *Entry, line []byte) error {
+	if err := e.UnmarshalFormat(line, p.Format); err != nil {
+		return err
+	}
+	if p.hasFieldDimensions() {
+		return e.unmarshalFields(line)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package stat
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// TypeRule is a request type classification rule, matched by access url path
+type TypeRule struct {
+	Prefix string         // url path prefix
+	Regexp *regexp.Regexp // url path regexp (used if prefix is empty)
+	Type   string         // request type
+	Params []string       // url query parameters, extracted as request query (like tag autocomplete expr)
+}
+
+// Match check url path by rule
+func (r *TypeRule) Match(path string) bool {
+	if r.Prefix != "" {
+		return strings.HasPrefix(path, r.Prefix)
+	}
+	if r.Regexp != nil {
+		return r.Regexp.MatchString(path)
+	}
+	return false
+}
+
+func (r TypeRule) String() string {
+	var s string
+	if r.Prefix != "" {
+		s = r.Type + "=prefix:" + r.Prefix
+	} else if r.Regexp != nil {
+		s = r.Type + "=regexp:" + r.Regexp.String()
+	}
+	if len(r.Params) > 0 {
+		s += ";params=" + strings.Join(r.Params, ",")
+	}
+	return s
+}
+
+// DefaultTypeRules is a request type rules for graphite-clickhouse http handlers (first matched rule is used)
+var DefaultTypeRules = []TypeRule{
+	{Prefix: "/render", Type: "render"},
+	{Prefix: "/metrics/find", Type: "metrics_find"},
+	{Prefix: "/metrics/index.json", Type: "metrics_index"},
+	{Prefix: "/tags/autoComplete/values", Type: "tag_values", Params: []string{"expr", "tag", "tagPrefix", "valuePrefix", "limit"}},
+	{Prefix: "/tags/autoComplete/tags", Type: "tag_names", Params: []string{"expr", "tag", "tagPrefix", "valuePrefix", "limit"}},
+	{Prefix: "/tags/tagSeries", Type: "tag_series"},
+	{Prefix: "/tags/tagMultiSeries", Type: "tag_series"},
+	{Prefix: "/tags/findSeries", Type: "tag_find_series", Params: []string{"expr"}},
+	{Prefix: "/read", Type: "prom_read"},
+	{Prefix: "/api/v1/query_range", Type: "prom_query_range", Params: []string{"query"}},
+	{Prefix: "/api/v1/query", Type: "prom_query", Params: []string{"query"}},
+	{Prefix: "/api/v1/series", Type: "prom_series", Params: []string{"match[]"}},
+	{Prefix: "/api/v1/labels", Type: "prom_labels", Params: []string{"match[]"}},
+	{Regexp: regexp.MustCompile(`^/api/v1/label/[^/]+/values`), Type: "prom_label_values", Params: []string{"match[]"}},
+	{Prefix: "/api/v1/metadata", Type: "prom_metadata"},
+	{Prefix: "/_internal/capabilities", Type: "capabilities"},
+	{Prefix: "/alive", Type: "health"},
+	{Prefix: "/health", Type: "health"},
+	{Prefix: "/debug/", Type: "debug"},
+}
+
+// ParseTypeRule parse request type rule, like tag_find_series=prefix:/tags/findSeries;params=expr or prom_label_values=regexp:^/api/v1/label/[^/]+/values
+func ParseTypeRule(s string) (TypeRule, error) {
+	var r TypeRule
+	name, rule, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return r, errors.New("invalid request type rule " + s + ", must be type=prefix:path[;params=p1,p2] or type=regexp:re[;params=p1,p2]")
+	}
+	r.Type = name
+	rule, params, _ := strings.Cut(rule, ";params=")
+	if params != "" {
+		r.Params = strings.Split(params, ",")
+	}
+	source, match, ok := strings.Cut(rule, ":")
+	if !ok || match == "" {
+		return r, errors.New("invalid request type rule " + s + ", must be type=prefix:path[;params=p1,p2] or type=regexp:re[;params=p1,p2]")
+	}
+	switch source {
+	case "prefix":
+		r.Prefix = match
+	case "regexp":
+		re, err := regexp.Compile(match)
+		if err != nil {
+			return r, errors.New("invalid request type rule " + s + ": " + err.Error())
+		}
+		r.Regexp = re
+	default:
+		return r, errors.New("invalid request type rule source " + source + ", must be prefix or regexp")
+	}
+	return r, nil
+}
+
+// requestType return request type and query (extracted from url parameters) by first matched rule
+func (p *Processor) requestType(u string) (requestType string, query string, ok bool) {
+	rules := p.TypeRules
+	if rules == nil {
+		rules = DefaultTypeRules
+	}
+	path, params, _ := strings.Cut(u, "?")
+	for i := range rules {
+		r := &rules[i]
+		if r.Match(path) {
+			if len(r.Params) > 0 {
+				query = paramsQuery(strings.Split(params, "&"), r.Params)
+			}
+			return r.Type, query, true
+		}
+	}
+	return "", "", false
+}
+
+// paramsQuery return query from url parameters in keys list (in url order): limit as is, other values as key='value'
+func paramsQuery(params []string, keys []string) string {
+	var sb strings.Builder
+	sb.Grow(128)
+	for _, a := range params {
+		k, v, _ := strings.Cut(a, "=")
+		if k == "" || v == "" {
+			continue
+		}
+		k, err := url.QueryUnescape(k)
+		if err != nil || !containsString(keys, k) {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(" ")
+		}
+		if k == "limit" {
+			sb.WriteString(a)
+		} else if p, err := url.QueryUnescape(v); err == nil {
+			sb.WriteString(k)
+			sb.WriteString("='")
+			if k, v, ok := strings.Cut(p, "="); ok {
+				k = strings.TrimSpace(k)
+				v = strings.TrimSpace(v)
+				sb.WriteString(k)
+				sb.WriteByte('=')
+				sb.WriteString(v)
+			} else {
+				sb.WriteString(p)
+			}
+			sb.WriteByte('\'')
+		} else {
+			sb.WriteString(a)
+		}
+	}
+	return sb.String()
+}
+
+func containsString(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package stat
+
+import (
+	"testing"
+)
+
+func TestProcessor_requestType(t *testing.T) {
+	custom, err := ParseTypeRule("tenant_render=regexp:^/t/[^/]+/render;params=target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		rules     []TypeRule
+		url       string
+		wantType  string
+		wantQuery string
+		wantOk    bool
+	}{
+		{name: "render", url: "/render/?format=carbonapi_v3_pb", wantType: "render", wantOk: true},
+		{name: "render without slash", url: "/render?target=test.a&format=json", wantType: "render", wantOk: true},
+		{name: "metrics index", url: "/metrics/index.json", wantType: "metrics_index", wantOk: true},
+		{
+			name: "tag values", url: "/tags/autoComplete/values?format=json&tag=c&expr=app+%3D+chproxy&limit=100",
+			wantType: "tag_values", wantQuery: "tag='c' expr='app=chproxy' limit=100", wantOk: true,
+		},
+		{
+			name: "find series", url: "/tags/findSeries?expr=name%3Dtest.a&expr=app%3Dchproxy",
+			wantType: "tag_find_series", wantQuery: "expr='name=test.a' expr='app=chproxy'", wantOk: true,
+		},
+		{name: "tag series", url: "/tags/tagSeries", wantType: "tag_series", wantOk: true},
+		{
+			name: "prometheus query range", url: "/api/v1/query_range?query=up&start=1674288223&end=1674288343&step=15",
+			wantType: "prom_query_range", wantQuery: "query='up'", wantOk: true,
+		},
+		{
+			name: "prometheus label values", url: "/api/v1/label/job/values?match%5B%5D=up",
+			wantType: "prom_label_values", wantQuery: "match[]='up'", wantOk: true,
+		},
+		{name: "unknown", url: "/unknown?target=test.a"},
+		{
+			name: "custom", rules: append([]TypeRule{custom}, DefaultTypeRules...), url: "/t/team1/render?target=test.a",
+			wantType: "tenant_render", wantQuery: "target='test.a'", wantOk: true,
+		},
+		{name: "custom fallback to defaults", rules: append([]TypeRule{custom}, DefaultTypeRules...), url: "/render/?target=test.a", wantType: "render", wantOk: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Processor{TypeRules: tt.rules}
+			gotType, gotQuery, gotOk := p.requestType(tt.url)
+			if gotType != tt.wantType || gotQuery != tt.wantQuery || gotOk != tt.wantOk {
+				t.Errorf("Processor.requestType() = (%q, %q, %v), want (%q, %q, %v)", gotType, gotQuery, gotOk, tt.wantType, tt.wantQuery, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestProcessor_requestType_autocomplete(t *testing.T) {
+	// tag autocomplete queries must be the same, as before request type rules
+	tests := []struct {
+		url       string
+		wantType  string
+		wantQuery string
+	}{
+		{
+			url:      "/tags/autoComplete/values?format=json&tag=1&valuePrefix=2&expr=app%3Dchproxy&expr=dc%3D1&limit=10",
+			wantType: "tag_values", wantQuery: "tag='1' valuePrefix='2' expr='app=chproxy' expr='dc=1' limit=10",
+		},
+		{
+			url:      "/tags/autoComplete/tags?tagPrefix=na&expr=name%3Dtest.a&limit=5",
+			wantType: "tag_names", wantQuery: "tagPrefix='na' expr='name=test.a' limit=5",
+		},
+		{
+			url:      "/tags/autoComplete/tags?tag=%zz&expr=a%3Db",
+			wantType: "tag_names", wantQuery: "tag=%zz expr='a=b'",
+		},
+		{
+			url:      "/tags/autoComplete/values?valuePrefix=&tag=env",
+			wantType: "tag_values", wantQuery: "tag='env'",
+		},
+	}
+	p := &Processor{}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			gotType, gotQuery, _ := p.requestType(tt.url)
+			if gotType != tt.wantType || gotQuery != tt.wantQuery {
+				t.Errorf("Processor.requestType() = (%q, %q), want (%q, %q)", gotType, gotQuery, tt.wantType, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestParseTypeRule(t *testing.T) {
+	for _, s := range []string{"render", "render=/render", "render=prefix:", "render=glob:/render", "render=regexp:(", "=prefix:/render"} {
+		if _, err := ParseTypeRule(s); err == nil {
+			t.Errorf("ParseTypeRule(%q) must fail", s)
+		}
+	}
+	r, err := ParseTypeRule("tag_find_series=prefix:/tags/findSeries;params=expr,limit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.String(), "tag_find_series=prefix:/tags/findSeries;params=expr,limit"; got != want {
+		t.Errorf("TypeRule.String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+package stat
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseDataQuery parse data query details: resample aggregate function and step, PREWHERE Date range and metrics list source
+//
+//	WITH anyResample(1674288230, 1674288349, 10)(toUInt32(intDiv(Time, 10)*10), Time) AS mask SELECT Path, arrayFilter(m->m!=0, mask) AS times,
+//	arrayFilter((v,m)->m!=0, avgResample(1674288230, 1674288349, 10)(Value, Time), mask) AS values FROM graphite_reversed
+//	PREWHERE Date >= '2023-01-21' AND Date <= '2023-01-21' WHERE (Path in metrics_list) AND (Time >= 1674288230 AND Time <= 1674288349) GROUP BY Path FORMAT RowBinary
+func parseDataQuery(q *DataStat, query string) {
+	q.AggFunc, q.Step = resampleFunc(query)
+
+	where := strings.Index(query, " WHERE ")
+	if start := strings.Index(query, " PREWHERE "); start > 0 {
+		prewhere := query[start+10:]
+		if where > start {
+			prewhere = query[start+10 : where]
+		}
+		q.DateFrom, q.DateUntil = dateRange(prewhere)
+	}
+	if where > 0 {
+		q.ExternalTable = strings.Contains(query[where:], "Path in metrics_list") || strings.Contains(query[where:], "Path IN metrics_list")
+	}
+}
+
+// resampleFunc return aggregate function and step of values resample (anyResample for time mask is skipped)
+func resampleFunc(query string) (aggFunc string, step int64) {
+	t := query
+	for {
+		n := strings.Index(t, "Resample(")
+		if n == -1 {
+			return "", 0
+		}
+		start := n
+		for start > 0 && isIdentChar(t[start-1]) {
+			start--
+		}
+		name := t[start:n]
+		args := t[n+9:]
+		t = args
+		if name == "" || name == "any" {
+			continue
+		}
+		// from, until, step
+		if end := strings.IndexByte(args, ')'); end > 0 {
+			params := strings.Split(args[:end], ",")
+			if len(params) == 3 {
+				step, _ = strconv.ParseInt(strings.TrimSpace(params[2]), 10, 64)
+			}
+		}
+		return name, step
+	}
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// dateRange return Date range (unix timestamps of days start) from condition like Date >= '2023-01-21' AND Date <= '2023-01-21' or Date = '2023-01-21'
+func dateRange(cond string) (from, until int64) {
+	if start := strings.Index(cond, "Date ="); start >= 0 {
+		if d, ok := quotedDate(strings.TrimLeft(cond[start+6:], " ")); ok {
+			return d.Unix(), d.Unix()
+		}
+	}
+	if start := strings.Index(cond, "Date >="); start >= 0 {
+		if d, ok := quotedDate(strings.TrimLeft(cond[start+7:], " ")); ok {
+			from = d.Unix()
+		}
+	}
+	if start := strings.Index(cond, "Date <="); start >= 0 {
+		if d, ok := quotedDate(strings.TrimLeft(cond[start+7:], " ")); ok {
+			until = d.Unix()
+		}
+	}
+	return
+}
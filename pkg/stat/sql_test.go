@@ -0,0 +1,50 @@
+package stat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseDataQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  DataStat
+	}{
+		{
+			name:  "resample",
+			query: "WITH anyResample(1674288230, 1674288349, 10)(toUInt32(intDiv(Time, 10)*10), Time) AS mask SELECT Path, arrayFilter(m->m!=0, mask) AS times, arrayFilter((v,m)->m!=0, maxResample(1674288230, 1674288349, 60)(Value, Time), mask) AS values FROM graphite_reversed PREWHERE Date >= '2023-01-19' AND Date <= '2023-01-21' WHERE (Path in metrics_list) AND (Time >= 1674288230 AND Time <= 1674288349) GROUP BY Path FORMAT RowBinary",
+			want:  DataStat{Step: 60, AggFunc: "max", DateFrom: 1674086400, DateUntil: 1674259200, ExternalTable: true},
+		},
+		{
+			name:  "group array",
+			query: "SELECT Path, groupArray(Time), groupArray(Value) FROM graphite_reversed PREWHERE Date >= '2023-01-21' AND Date <= '2023-01-21' WHERE (Path in metrics_list) AND (Time >= 1674288230 AND Time <= 1674288349) GROUP BY Path",
+			want:  DataStat{DateFrom: 1674259200, DateUntil: 1674259200, ExternalTable: true},
+		},
+		{
+			name:  "single date and inline metrics",
+			query: "SELECT Path, groupArray(Time), groupArray(Value) FROM graphite PREWHERE (Date = '2023-01-21') WHERE (Path IN ('test.a','test.b')) AND (Time >= 1674288230 AND Time <= 1674288349) GROUP BY Path",
+			want:  DataStat{DateFrom: 1674259200, DateUntil: 1674259200},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got DataStat
+			parseDataQuery(&got, tt.query)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDataQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataStat_DateDays(t *testing.T) {
+	q := DataStat{DateFrom: 1674086400, DateUntil: 1674259200}
+	if got := q.DateDays(); got != 3 {
+		t.Errorf("DataStat.DateDays() = %d, want 3", got)
+	}
+	q = DataStat{}
+	if got := q.DateDays(); got != 0 {
+		t.Errorf("DataStat.DateDays() = %d, want 0", got)
+	}
+}
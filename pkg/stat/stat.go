@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -52,23 +51,20 @@ func stripError(err string) string {
 	return err
 }
 
-func readError(logEntry map[string]interface{}, key string) string {
-	if item, ok := logEntry[key]; ok {
-		if v, ok := item.(string); ok {
-			if start := strings.Index(v, " cannot reach "); start >= 0 {
-				err := v[start+1:]
-				if end := strings.Index(err, ";"); end > 0 {
-					err = err[0:end]
-				}
-
-				return err
-			}
-
-			return stripError(v)
+func parseError(v string) string {
+	if v == "" {
+		return ""
+	}
+	if start := strings.Index(v, " cannot reach "); start >= 0 {
+		err := v[start+1:]
+		if end := strings.Index(err, ";"); end > 0 {
+			err = err[0:end]
 		}
+
+		return err
 	}
 
-	return ""
+	return stripError(v)
 }
 
 func readString(logEntry map[string]interface{}, key string) (string, error) {
@@ -155,35 +151,19 @@ func readBool(logEntry map[string]interface{}, key string) (bool, error) {
 	}
 }
 
-func readUsername(logEntry map[string]interface{}, key, userKey string) (string, error) {
-	if item, ok := logEntry[key]; ok {
-		if v, ok := item.(map[string]interface{}); ok {
-			if userItem, ok := v[userKey]; ok {
-				if user, ok := userItem.(string); ok {
-					return user, nil
-				} else {
-					return "", errors.New("user key " + key + "not a string")
-				}
-			} else {
-				return "", nil
-			}
-		} else {
-			return "", errors.New("key " + key + "not a map")
-		}
-	} else {
-		return "", errors.New("key " + key + "not found")
-	}
-}
-
 type Query struct {
 	Days  int
 	Query string
 	From  int64
 	Until int64
+	Terms []TagTerm `json:",omitempty"` // seriesByTag terms of target
+
+	Metrics int64 `json:",omitempty"` // found metrics of target (from render finder entry)
 }
 
 type IndexStat struct {
-	Status Status
+	TimeStamp int64 // query (or finder cache hit) entry timestamp (unix nano)
+	Status    Status
 	// Rows      int64
 	ReadRows  int64
 	ReadBytes int64
@@ -192,10 +172,18 @@ type IndexStat struct {
 	QueryId   string
 	Days      int
 	Error     string
+	Tag1      string `json:",omitempty"` // Tag1 prefilter condition of tagged index query
+	Targets   []int  `json:",omitempty"` // linked targets (Queries indexes) of render request
+}
+
+// Tagged return true for tagged index query (with Tag1 prefilter)
+func (q *IndexStat) Tagged() bool {
+	return q.Tag1 != ""
 }
 
 type DataStat struct {
-	Status Status
+	TimeStamp int64 // query entry timestamp (unix nano)
+	Status    Status
 	// Rows      int64
 	ReadRows  int64
 	ReadBytes int64
@@ -206,18 +194,49 @@ type DataStat struct {
 	From      int64
 	Until     int64
 	Error     string
+
+	Step          int64  `json:",omitempty"` // resample step (seconds, 0 if not resampled)
+	AggFunc       string `json:",omitempty"` // resample aggregate function (like avg)
+	DateFrom      int64  `json:",omitempty"` // PREWHERE Date range start (unix, day start)
+	DateUntil     int64  `json:",omitempty"` // PREWHERE Date range end (unix, day start)
+	ExternalTable bool   `json:",omitempty"` // metrics list is passed as external table (metrics_list)
+
+	// data_parse stat of query result, best-effort: data_parse entry has no table or time range,
+	// so it's linked to the first not parsed query (results of concurrent queries may be parsed out of order).
+	// Request Points, Bytes and DataParseTime sums are exact.
+	Parsed    bool    `json:",omitempty"` // data_parse entry is linked (result may be empty)
+	Points    int64   `json:",omitempty"`
+	Bytes     int64   `json:",omitempty"`
+	ParseTime float64 `json:",omitempty"` // parse runtime (seconds)
+
+	Targets []int `json:",omitempty"` // linked targets (Queries indexes) of render request, several for shared query
+}
+
+// DateDays return days in PREWHERE Date range (partitions days scanned by query), 0 if range not parsed
+func (q *DataStat) DateDays() int {
+	if q.DateFrom == 0 || q.DateUntil < q.DateFrom {
+		return 0
+	}
+	return int((q.DateUntil-q.DateFrom)/(3600*24)) + 1
 }
 
 type Stat struct {
 	Id string
 
-	TimeStamp int64
+	TimeStamp int64 // last entry timestamp (unix nano)
+	Start     int64 // first entry timestamp (unix nano)
+
+	// request stages timestamps (unix nano), see Timeline
+	ParseTimeStamp     int64 // last target parse
+	FindTimeStamp      int64 // last finder
+	DataParseTimeStamp int64 // last data parse
 
 	Queries []Query
 
-	Metrics int64
-	Points  int64
-	Bytes   int64
+	Metrics       int64
+	Points        int64   // parsed points (sum of data_parse entries)
+	Bytes         int64   // parsed bytes (sum of data_parse entries)
+	DataParseTime float64 // data parse runtime (seconds, sum of data_parse entries)
 
 	RequestType   string
 	RequestTime   float64
@@ -238,6 +257,30 @@ type Stat struct {
 	Data          []DataStat
 
 	Username string
+	Instance string // instance label (host or pod name) from entry field, log envelope or input path
+
+	// grafana and carbonapi context from request headers
+	GrafanaOrgId  string // X-Grafana-Org-Id
+	DashboardId   string // X-Dashboard-Id
+	PanelId       string // X-Panel-Id
+	CarbonapiUuid string // X-Ctx-Carbonapi-Uuid
+
+	Dimensions map[string]string `json:",omitempty"` // custom dimensions, configured in processor
+
+	Lines []string `json:",omitempty"` // raw log lines (if enabled in reader)
+}
+
+// QueryKey return in-flight requests map key: request id, prefixed by instance (request ids from several instances can collide)
+func QueryKey(instance, requestId string) string {
+	if instance == "" {
+		return requestId
+	}
+	return instance + "/" + requestId
+}
+
+// Key return in-flight requests map key
+func (s *Stat) Key() string {
+	return QueryKey(s.Instance, s.Id)
 }
 
 func (s *Stat) MaxDuration() int64 {
@@ -264,11 +307,16 @@ func (s *Stat) MaxDuration() int64 {
 
 func (s *Stat) Reset(ts int64) {
 	s.TimeStamp = ts
+	s.Start = ts
+	s.ParseTimeStamp = 0
+	s.FindTimeStamp = 0
+	s.DataParseTimeStamp = 0
 	s.Queries = make([]Query, 0)
 
 	s.Metrics = 0
 	s.Points = 0
 	s.Bytes = 0
+	s.DataParseTime = 0
 
 	s.RequestType = ""
 	s.RequestTime = 0
@@ -288,6 +336,37 @@ func (s *Stat) Reset(ts int64) {
 	s.DataReadRows = 0
 	s.DataReadBytes = 0
 	s.Data = make([]DataStat, 0)
+
+	s.GrafanaOrgId = ""
+	s.DashboardId = ""
+	s.PanelId = ""
+	s.CarbonapiUuid = ""
+	s.Dimensions = nil
+
+	s.Lines = nil
+}
+
+// Clone return stat copy (for read in-flight request, while it's processed)
+func (s *Stat) Clone() *Stat {
+	c := *s
+	c.Queries = append([]Query(nil), s.Queries...)
+	c.Index = append([]IndexStat(nil), s.Index...)
+	c.Data = append([]DataStat(nil), s.Data...)
+	c.Dimensions = copyMap(s.Dimensions)
+	return &c
+}
+
+// StartTimeStamp return first entry timestamp (unix nano) or last entry timestamp, if start is not recorded (stat from old checkpoint)
+func (s *Stat) StartTimeStamp() int64 {
+	if s.Start == 0 {
+		return s.TimeStamp
+	}
+	return s.Start
+}
+
+// Pending return request pending duration (nanoseconds) at log time now
+func (s *Stat) Pending(now int64) int64 {
+	return now - s.StartTimeStamp()
 }
 
 func (s *Stat) TotalQueryRows() float64 {
@@ -492,51 +571,6 @@ func LessStat(a, b *Stat, sortKey Sort) bool {
 	}
 }
 
-// func abs(a int64) int64 {
-// 	if a < 0 {
-// 		return -a
-// 	}
-// 	return a
-// }
-
-func tagQuery(params []string) string {
-	var sb strings.Builder
-	sb.Grow(128)
-	for _, a := range params {
-		k, v, _ := strings.Cut(a, "=")
-		if k != "" && v != "" {
-			switch k {
-			case "limit":
-				if sb.Len() > 0 {
-					sb.WriteString(" ")
-				}
-				sb.WriteString(a)
-			case "expr", "tag", "tagPrefix", "valuePrefix":
-				if sb.Len() > 0 {
-					sb.WriteString(" ")
-				}
-				if p, err := url.QueryUnescape(v); err == nil {
-					sb.WriteString(k)
-					sb.WriteString("='")
-					if k, v, ok := strings.Cut(p, "="); ok {
-						k = strings.TrimSpace(k)
-						v = strings.TrimSpace(v)
-						sb.WriteString(k)
-						sb.WriteByte('=')
-						sb.WriteString(v)
-					} else {
-						sb.WriteString(p)
-					}
-					sb.WriteByte('\'')
-				} else {
-					sb.WriteString(a)
-				}
-			}
-		}
-	}
-	return sb.String()
-}
-
 func metricsFindCacheQuery(cacheKey string) (string, bool) {
 	// 1970-02-12;query=test.c*;ts=1674288000
 	if strings.HasPrefix(cacheKey, "1970-02-12;query=") {
@@ -548,67 +582,150 @@ func metricsFindCacheQuery(cacheKey string) (string, bool) {
 	return "", false
 }
 
-func LogEntryProcess(logEntry map[string]interface{}, queries map[string]*Stat) string {
+// unparsedData return first successful data query without linked data_parse entry (best-effort, see DataStat.Parsed)
+func (s *Stat) unparsedData() *DataStat {
+	for i := range s.Data {
+		if q := &s.Data[i]; q.Status == StatusSuccess && !q.Parsed {
+			return q
+		}
+	}
+	return nil
+}
+
+// quotedDate parse quoted date ('2006-01-02') at the start of string
+func quotedDate(t string) (time.Time, bool) {
+	if len(t) < 12 || t[0] != '\'' || t[11] != '\'' {
+		return time.Time{}, false
+	}
+	d, err := time.Parse("2006-01-02", t[1:11])
+	return d, err == nil
+}
+
+// readContextHeaders set grafana and carbonapi context from request headers (missed headers don't reset context)
+func readContextHeaders(v *Stat, headers map[string]string) {
+	if len(headers) == 0 {
+		return
+	}
+	if h := headers["X-Grafana-Org-Id"]; h != "" {
+		v.GrafanaOrgId = h
+	}
+	if h := headers["X-Dashboard-Id"]; h != "" {
+		v.DashboardId = h
+	}
+	if h := headers["X-Panel-Id"]; h != "" {
+		v.PanelId = h
+	}
+	if h := headers["X-Ctx-Carbonapi-Uuid"]; h != "" {
+		v.CarbonapiUuid = h
+	}
+}
+
+// LogEntryProcess process log entry, decoded to map
+func LogEntryProcess(logEntry map[string]interface{}, queries map[string]*Stat) (string, error) {
+	var e Entry
+	e.FromMap(logEntry)
+	return EntryProcess(&e, queries)
+}
+
+// EntryProcess process log entry with default processor and return request key (see QueryKey), if request is completed.
+// Malformed entry is not processed and *ParseError is returned.
+func EntryProcess(e *Entry, queries map[string]*Stat) (string, error) {
+	return defaultProcessor.EntryProcess(e, queries)
+}
+
+// EntryProcess process log entry and return request key (see QueryKey), if request is completed.
+// Malformed entry is not processed and *ParseError is returned.
+// Leaked request (not completed in LeakTimeout) is reset.
+func (p *Processor) EntryProcess(e *Entry, queries map[string]*Stat) (string, error) {
+	return p.EntryProcessOrphans(e, queries, nil)
+}
+
+// Validate check entry for process errors (like invalid timestamp), which not depend on in-flight requests
+func (p *Processor) Validate(e *Entry) error {
+	_, err := p.validate(e)
+	return err
+}
+
+func (p *Processor) validate(e *Entry) (int64, error) {
+	ts, err := p.TimeLayout.Parse(string(e.TimeStamp))
+	if err != nil {
+		return 0, newParseError(ReasonInvalidTimeStamp, strconv.Quote(string(e.TimeStamp)))
+	}
+	if e.Message == "query" && e.Logger == "autocomplete" && e.Query != "" && !strings.Contains(string(e.Query), " FROM ") {
+		return 0, newParseError(ReasonInvalidQuery, "no FROM in autocomplete query")
+	}
+	return ts, nil
+}
+
+// EntryProcessOrphans is a EntryProcess, but leaked request (not completed in LeakTimeout) is passed to orphaned callback
+// and replaced by new request stat.
+func (p *Processor) EntryProcessOrphans(e *Entry, queries map[string]*Stat, orphaned func(s *Stat)) (string, error) {
 	var flushed bool
-	request_id, ok := logEntry["request_id"].(string)
-	if !ok {
-		return ""
+	request_id := e.RequestId
+	if request_id == "" {
+		return "", nil
 	}
 
-	timeStamp, err := time.Parse("2006-01-02T15:04:05.000-0700", logEntry["timestamp"].(string))
+	// validate before request stat is touched, so malformed entry has no side effects
+	ts, err := p.validate(e)
 	if err != nil {
-		fmt.Printf("%v\n", err)
-		return ""
+		return "", err
 	}
-	ts := timeStamp.UnixNano()
 
-	v, ok := queries[request_id]
+	key := QueryKey(e.Instance, request_id)
+	v, ok := queries[key]
 	if ok {
-		// leak record, reset
-		if ts > v.TimeStamp+240*1e9 || ts < v.TimeStamp {
-			v.Reset(ts)
+		if ts > v.TimeStamp+int64(p.LeakTimeout) || ts < v.TimeStamp {
+			// leak record
+			if orphaned == nil {
+				v.Reset(ts)
+			} else {
+				orphaned(v)
+				v = &Stat{Id: request_id, Instance: e.Instance, TimeStamp: ts, Start: ts}
+				queries[key] = v
+			}
 		} else {
 			v.TimeStamp = ts
 		}
 	} else {
-		v = &Stat{Id: request_id, TimeStamp: ts}
-		queries[request_id] = v
+		v = &Stat{Id: request_id, Instance: e.Instance, TimeStamp: ts, Start: ts}
+		queries[key] = v
 	}
 
-	v.Username, _ = readUsername(logEntry, "request_headers", "X-Forwarded-User")
+	v.Username = e.RequestHeaders["X-Forwarded-User"]
+	readContextHeaders(v, e.RequestHeaders)
+	p.readDimensions(v, e)
 
-	level := logEntry["level"].(string)
+	level := e.Level
 
 	v.TimeStamp = ts
 
-	logger := logEntry["logger"].(string)
-	message := logEntry["message"].(string)
+	logger := e.Logger
+	message := e.Message
 
 	if message == "query" {
 		if logger == "metrics-find" {
-			if item, ok := logEntry["query"]; ok {
-				q := IndexStat{}
-				query := item.(string)
+			if e.Query != "" {
+				q := IndexStat{TimeStamp: ts}
+				query := string(e.Query)
 
 				if level == "ERROR" {
 					q.Status = StatusError
-					q.Error = readError(logEntry, "error")
+					q.Error = p.ErrorClass(e.Error)
 				} else {
 					q.Status = StatusSuccess
 				}
 
-				if item, ok = logEntry["query_id"]; ok {
-					q.QueryId = item.(string)
-				}
+				q.QueryId = e.QueryId
 
-				q.ReadRows, _ = readInt64(logEntry, "read_rows")
+				q.ReadRows = int64(e.ReadRows)
 				v.IndexReadRows += q.ReadRows
 				v.ReadRows += q.ReadRows
-				q.ReadBytes, _ = readInt64(logEntry, "read_bytes")
+				q.ReadBytes = int64(e.ReadBytes)
 				v.IndexReadBytes += q.ReadBytes
 				v.ReadBytes += q.ReadBytes
 
-				q.Time, _ = readFloat64(logEntry, "time")
+				q.Time = float64(e.Time)
 
 				if start := strings.Index(query, " FROM "); start > 0 {
 					t := query[start+6:]
@@ -619,56 +736,46 @@ func LogEntryProcess(logEntry map[string]interface{}, queries map[string]*Stat)
 				v.Index = append(v.Index, q)
 			}
 		} else if logger == "autocomplete" {
-			if item, ok := logEntry["query"]; ok {
-				q := IndexStat{}
-				query := item.(string)
+			if e.Query != "" {
+				q := IndexStat{TimeStamp: ts}
+				query := string(e.Query)
+
+				start := strings.Index(query, " FROM ") // already validated
 
 				if level == "ERROR" {
 					q.Status = StatusError
-					q.Error = readError(logEntry, "error")
+					q.Error = p.ErrorClass(e.Error)
 				} else {
 					q.Status = StatusSuccess
 				}
 
-				if item, ok = logEntry["query_id"]; ok {
-					q.QueryId = item.(string)
-				}
+				q.QueryId = e.QueryId
 
-				q.ReadRows, _ = readInt64(logEntry, "read_rows")
+				q.ReadRows = int64(e.ReadRows)
 				v.IndexReadRows += q.ReadRows
 				v.ReadRows += q.ReadRows
-				q.ReadBytes, _ = readInt64(logEntry, "read_bytes")
+				q.ReadBytes = int64(e.ReadBytes)
 				v.IndexReadBytes += q.ReadBytes
 				v.ReadBytes += q.ReadBytes
 
-				q.Time, _ = readFloat64(logEntry, "time")
+				q.Time = float64(e.Time)
 
-				start := strings.Index(query, " FROM ")
-				if start > 0 {
-					t := query[start+6:]
-					end := strings.Index(t, " ")
-					if end > 0 {
-						q.Table = t[0:end]
-					}
+				t := query[start+6:]
+				if end := strings.Index(t, " "); end > 0 {
+					q.Table = t[0:end]
 				}
-
-				t := query[start:]
 				if start := strings.Index(t, ") AND (Date >="); start > 0 {
 					t = strings.TrimLeft(t[start+14:], " ")
-					if t[0] == '\'' && t[11] == '\'' {
-						if startDay, err := time.Parse("2006-01-02", t[1:11]); err == nil {
-							t = t[11:]
-							if end := strings.Index(t, " AND Date <="); end > 0 {
-								t = strings.TrimLeft(t[end+12:], " ")
-								if t[0] == '\'' && t[11] == '\'' {
-									if endDay, err := time.Parse("2006-01-02", t[1:11]); err == nil {
-										q.Days = int(endDay.Sub(startDay).Seconds()) / (3600 * 24)
-									}
-								}
-							} else {
-								// old query format : without end date
-								q.Days = int(timeStamp.Truncate(24*time.Hour).Sub(startDay).Seconds()) / (3600 * 24)
+					if startDay, ok := quotedDate(t); ok {
+						t = t[11:]
+						if end := strings.Index(t, " AND Date <="); end > 0 {
+							t = strings.TrimLeft(t[end+12:], " ")
+							if endDay, ok := quotedDate(t); ok {
+								q.Days = int(endDay.Sub(startDay).Seconds()) / (3600 * 24)
 							}
+						} else {
+							// old query format : without end date
+							q.Days = int(time.Unix(0, ts).Truncate(24*time.Hour).Sub(startDay).Seconds()) / (3600 * 24)
 						}
 					}
 				}
@@ -676,30 +783,27 @@ func LogEntryProcess(logEntry map[string]interface{}, queries map[string]*Stat)
 			}
 		} else if logger == "render" {
 			// clickhouse query stat
-			if item, ok := logEntry["query"]; ok {
-				query := item.(string)
+			if e.Query != "" {
+				query := string(e.Query)
 				indexQuery := strings.HasPrefix(query, "SELECT Path FROM ")
 				if indexQuery {
 					// index query
-					var q IndexStat
+					q := IndexStat{TimeStamp: ts}
 
 					t := query[17:]
 					end := strings.Index(t, " ")
 					if end > 0 {
 						q.Table = t[0:end]
 					}
+					q.Tag1 = tag1Condition(t)
 					if start := strings.Index(t, ") AND (Date >="); start > 0 {
 						t = strings.TrimLeft(t[start+14:], " ")
-						if t[0] == '\'' && t[11] == '\'' {
-							if startDay, err := time.Parse("2006-01-02", t[1:11]); err == nil {
-								t = t[11:]
-								if end := strings.Index(t, " AND Date <="); end > 0 {
-									t = strings.TrimLeft(t[end+12:], " ")
-									if t[0] == '\'' && t[11] == '\'' {
-										if endDay, err := time.Parse("2006-01-02", t[1:11]); err == nil {
-											q.Days = int(endDay.Sub(startDay).Seconds())/(3600*24) + 1
-										}
-									}
+						if startDay, ok := quotedDate(t); ok {
+							t = t[11:]
+							if end := strings.Index(t, " AND Date <="); end > 0 {
+								t = strings.TrimLeft(t[end+12:], " ")
+								if endDay, ok := quotedDate(t); ok {
+									q.Days = int(endDay.Sub(startDay).Seconds())/(3600*24) + 1
 								}
 							}
 						}
@@ -707,49 +811,45 @@ func LogEntryProcess(logEntry map[string]interface{}, queries map[string]*Stat)
 
 					if level == "ERROR" {
 						q.Status = StatusError
-						q.Error = readError(logEntry, "error")
+						q.Error = p.ErrorClass(e.Error)
 					} else {
 						q.Status = StatusSuccess
 					}
 
-					if item, ok = logEntry["query_id"]; ok {
-						q.QueryId = item.(string)
-					}
+					q.QueryId = e.QueryId
 
-					q.ReadRows, _ = readInt64(logEntry, "read_rows")
+					q.ReadRows = int64(e.ReadRows)
 					v.IndexReadRows += q.ReadRows
 					v.ReadRows += q.ReadRows
-					q.ReadBytes, _ = readInt64(logEntry, "read_bytes")
+					q.ReadBytes = int64(e.ReadBytes)
 					v.IndexReadBytes += q.ReadBytes
 					v.ReadBytes += q.ReadBytes
 
-					q.Time, _ = readFloat64(logEntry, "time")
+					q.Time = float64(e.Time)
 
 					v.Index = append(v.Index, q)
 
 				} else {
 					// data query
-					q := DataStat{}
+					q := DataStat{TimeStamp: ts}
 
 					if level == "ERROR" {
 						q.Status = StatusError
-						q.Error = readError(logEntry, "error")
+						q.Error = p.ErrorClass(e.Error)
 					} else {
 						q.Status = StatusSuccess
 					}
 
-					if item, ok = logEntry["query_id"]; ok {
-						q.QueryId = item.(string)
-					}
+					q.QueryId = e.QueryId
 
-					q.ReadRows, _ = readInt64(logEntry, "read_rows")
+					q.ReadRows = int64(e.ReadRows)
 					v.DataReadRows += q.ReadRows
 					v.ReadRows += q.ReadRows
-					q.ReadBytes, _ = readInt64(logEntry, "read_bytes")
+					q.ReadBytes = int64(e.ReadBytes)
 					v.DataReadBytes += q.ReadBytes
 					v.ReadBytes += q.ReadBytes
 
-					q.Time, _ = readFloat64(logEntry, "time")
+					q.Time = float64(e.Time)
 
 					start := strings.Index(query, " FROM ")
 					if start > 0 {
@@ -782,6 +882,9 @@ func LogEntryProcess(logEntry map[string]interface{}, queries map[string]*Stat)
 						}
 					}
 
+					parseDataQuery(&q, query)
+					q.Targets = v.dataTargets(q.From, q.Until)
+
 					v.Data = append(v.Data, q)
 				}
 			}
@@ -791,37 +894,38 @@ func LogEntryProcess(logEntry map[string]interface{}, queries map[string]*Stat)
 			(logger == "render.json_parser" && message == "json_target") ||
 			(logger == "render.form_parser" && message == "target") {
 
-			if item, ok := logEntry["target"]; ok {
+			v.ParseTimeStamp = ts
+			if e.Target != "" {
 				q := Query{
-					Query: item.(string),
+					Query: e.Target,
 				}
-				q.From, _ = readInt64(logEntry, "from")
-				q.Until, _ = readInt64(logEntry, "until")
+				q.From = int64(e.From)
+				q.Until = int64(e.Until)
 				if q.From > 0 && q.Until > 0 {
 					duration := q.Until - q.From
 					q.Days = int((duration)/(3600*24) + 1)
 
 				}
+				q.Terms, _ = ParseSeriesByTag(e.Target)
 				v.Queries = append(v.Queries, q)
 			}
 
 		} else if message == "finder" && (logger == "render" || logger == "metrics-find" || logger == "autocomplete") {
 			// find stat
-			if item, ok := logEntry["find_cached"]; ok {
-				if _, ok := logEntry["ttl"]; ok {
-					indexCached := item.(bool)
-					metrics, _ := readInt64(logEntry, "metrics")
-					v.Metrics += metrics
+			v.FindTimeStamp = ts
+			if e.FindCached.Set {
+				if e.TTL {
+					indexCached := e.FindCached.Value
+					v.Metrics += int64(e.Metrics)
 					if indexCached {
-						cacheKey, _ := readString(logEntry, "get_cache")
-						if query, ok := metricsFindCacheQuery(cacheKey); ok {
+						if query, ok := metricsFindCacheQuery(e.GetCache); ok {
 							// metrics/find
 							v.Queries = append(v.Queries, Query{Query: query})
 						}
-						q := IndexStat{}
-						from, _ := readInt64(logEntry, "from")
-						until, _ := readInt64(logEntry, "until")
-						// q.Query, _ = readString(logEntry, "target")
+						q := IndexStat{TimeStamp: ts}
+						from := int64(e.From)
+						until := int64(e.Until)
+						// q.Query = e.Target
 						if until > 0 && from > 0 {
 							q.Days = int(until-from)/(3600*24) + 1
 						}
@@ -829,73 +933,51 @@ func LogEntryProcess(logEntry map[string]interface{}, queries map[string]*Stat)
 						q.Status = StatusCached
 						v.Index = append(v.Index, q)
 					} else {
-						cacheKey, _ := readString(logEntry, "set_cache")
-						if query, ok := metricsFindCacheQuery(cacheKey); ok {
+						if query, ok := metricsFindCacheQuery(e.SetCache); ok {
 							// metrics/find
 							v.Queries = append(v.Queries, Query{Query: query})
 						}
 					}
+					if logger == "render" {
+						v.linkTarget(e)
+					}
 				}
 			}
 		} else if logger == "render" && message == "data_parse" {
-			v.Points, _ = readInt64(logEntry, "read_points")
-			v.Bytes, _ = readInt64(logEntry, "read_bytes")
+			v.DataParseTimeStamp = ts
+			v.Points += int64(e.ReadPoints)
+			v.Bytes += int64(e.ReadBytes)
+			v.DataParseTime += float64(e.RuntimeNs)
+			if q := v.unparsedData(); q != nil {
+				q.Parsed = true
+				q.Points = int64(e.ReadPoints)
+				q.Bytes = int64(e.ReadBytes)
+				q.ParseTime = float64(e.RuntimeNs)
+			}
 		} else if logger == "http" && message == "access" {
 			// end of query stat
 			flushed = true
 
-			v.RequestStatus, _ = readInt64(logEntry, "status")
-			v.RequestTime, _ = readFloat64(logEntry, "time")
-			v.WaitTime, _ = readFloat64(logEntry, "wait_slot")
+			v.RequestStatus = int64(e.Status)
+			v.RequestTime = float64(e.Time)
+			v.WaitTime = float64(e.WaitSlot)
 			v.QueryTime = v.RequestTime - v.WaitTime
-			if waitFail, err := readBool(logEntry, "wait_fail"); waitFail {
+			if e.WaitFail.Value {
 				v.WaitStatus = StatusError
-			} else if err == nil {
+			} else if e.WaitFail.Set {
 				v.WaitStatus = StatusSuccess
 			}
 
-			// if len(v.IndexQuery) == 0 {
-			// 	query, _ := readStringSlice(logEntry, "query")
-			// 	if len(query) > 0 {
-			// 		for _, q := range query {
-			// 			v.IndexQuery = append(v.IndexQuery, Query{Query: q})
-			// 		}
-			// 	}
-			// }
-
-			url := logEntry["url"].(string)
-			if strings.HasPrefix(url, "/render/?") {
-				v.RequestType = "render"
-			} else if strings.HasPrefix(url, "/metrics/find/?") {
-				v.RequestType = "metrics_find"
-			} else if strings.HasPrefix(url, "/tags/autoComplete/values?") {
-				v.RequestType = "tag_values"
-				query := url[26:]
-				params := strings.Split(query, "&")
-				v.Queries = []Query{{Query: tagQuery(params)}}
-			} else if strings.HasPrefix(url, "/tags/autoComplete/tags?") {
-				v.RequestType = "tag_names"
-				query := url[24:]
-				params := strings.Split(query, "&")
-				v.Queries = []Query{{Query: tagQuery(params)}}
+			if requestType, query, ok := p.requestType(e.URL); ok {
+				v.RequestType = requestType
+				if query != "" {
+					v.Queries = []Query{{Query: query}}
+				}
 			}
 		} else if logger == "autocomplete" && message == "finder" {
 			// tags autocompleter cache stat
-			// if _, ok := logEntry["find_cached"]; ok {
-			// indexCached := item.(bool)
-			// if indexCached {
-			// 	if item, ok := logEntry["get_cache"]; ok {
-			// 		v.IndexStatus = StatusCached
-			// 		v.Target = item.(string)
-			// 	}
-			// } else {
-			// 	if item, ok := logEntry["set_cache"]; ok {
-			// 		v.Target = item.(string)
-			// 	}
-			// }
-			// }
 			if v.Metrics == 0 {
-				v.Metrics, _ = readInt64(logEntry, "metrics")
+				v.Metrics = int64(e.Metrics)
 			}
 			// {
 			// 	"level":"INFO","timestamp":"2022-01-13T09:08:56.605+0500",
@@ -907,8 +989,8 @@ func LogEntryProcess(logEntry map[string]interface{}, queries map[string]*Stat)
 	}
 
 	if flushed {
-		return request_id
+		return key, nil
 	} else {
-		return ""
+		return "", nil
 	}
 }
@@ -0,0 +1,149 @@
+package stat
+
+import (
+	"errors"
+	"strings"
+)
+
+// TagOp is a seriesByTag term operator
+type TagOp int8
+
+const (
+	TagEq       TagOp = iota // =
+	TagNe                    // !=
+	TagMatch                 // =~
+	TagNotMatch              // !=~
+)
+
+var tagOpStrings []string = []string{"=", "!=", "=~", "!=~"}
+
+func (op TagOp) String() string {
+	return tagOpStrings[op]
+}
+
+// TagTerm is a seriesByTag tag expression term, like name=value or app=~regex
+type TagTerm struct {
+	Key   string
+	Op    TagOp
+	Value string
+}
+
+func (t TagTerm) String() string {
+	return t.Key + t.Op.String() + t.Value
+}
+
+// ParseTagTerm parse seriesByTag tag expression term (name is an alias of __name__)
+func ParseTagTerm(s string) (TagTerm, error) {
+	var t TagTerm
+	n := strings.IndexAny(s, "!=")
+	if n <= 0 {
+		return t, errors.New("invalid tag expression " + s)
+	}
+	t.Key = strings.TrimSpace(s[:n])
+	s = s[n:]
+	switch {
+	case strings.HasPrefix(s, "!=~"):
+		t.Op = TagNotMatch
+		s = s[3:]
+	case strings.HasPrefix(s, "!="):
+		t.Op = TagNe
+		s = s[2:]
+	case strings.HasPrefix(s, "=~"):
+		t.Op = TagMatch
+		s = s[2:]
+	case strings.HasPrefix(s, "="):
+		t.Op = TagEq
+		s = s[1:]
+	default:
+		return t, errors.New("invalid tag expression operator " + s)
+	}
+	if t.Key == "" {
+		return t, errors.New("empty tag in expression " + s)
+	}
+	if t.Key == "name" {
+		t.Key = "__name__"
+	}
+	t.Value = strings.TrimSpace(s)
+	return t, nil
+}
+
+// ParseSeriesByTag return tag expressions terms of all seriesByTag calls in target (false if target has no seriesByTag calls)
+func ParseSeriesByTag(target string) ([]TagTerm, bool) {
+	var (
+		terms []TagTerm
+		found bool
+	)
+	for {
+		n := strings.Index(target, "seriesByTag(")
+		if n == -1 {
+			break
+		}
+		found = true
+		target = target[n+12:]
+	ARGS:
+		for {
+			target = strings.TrimLeft(target, " ,")
+			if target == "" {
+				break
+			}
+			switch quote := target[0]; quote {
+			case '\'', '"':
+				end := strings.IndexByte(target[1:], quote)
+				if end == -1 {
+					target = ""
+					break ARGS
+				}
+				if t, err := ParseTagTerm(target[1 : end+1]); err == nil {
+					terms = append(terms, t)
+				}
+				target = target[end+2:]
+			default:
+				// end of call or not a quoted term
+				break ARGS
+			}
+		}
+	}
+	return terms, found
+}
+
+// tag1Condition return Tag1 prefilter condition of tagged index query, like Tag1='__name__=test'
+func tag1Condition(query string) string {
+	start := strings.Index(query, "Tag1")
+	if start == -1 {
+		return ""
+	}
+	t := query[start:]
+	var (
+		depth int
+		quote bool
+	)
+	for i := 0; i < len(t); i++ {
+		switch c := t[i]; {
+		case c == '\\' && quote:
+			i++
+		case c == '\'':
+			quote = !quote
+		case quote:
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				return t[:i]
+			}
+			depth--
+		case depth == 0 && strings.HasPrefix(t[i:], " AND "):
+			return t[:i]
+		}
+	}
+	return t
+}
+
+// TermOf return term of tag terms, used in Tag1 prefilter condition (by tag key)
+func TermOf(terms []TagTerm, tag1 string) (TagTerm, bool) {
+	for _, t := range terms {
+		if strings.Contains(tag1, "'"+t.Key+"=") {
+			return t, true
+		}
+	}
+	return TagTerm{}, false
+}
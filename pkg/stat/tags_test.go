@@ -0,0 +1,81 @@
+package stat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSeriesByTag(t *testing.T) {
+	tests := []struct {
+		target    string
+		want      []TagTerm
+		wantFound bool
+	}{
+		{target: "test.a"},
+		{
+			target: "seriesByTag('name=test', 'app=~ch.*', \"env!=dev\", 'dc!=~(a|b)')",
+			want: []TagTerm{
+				{Key: "__name__", Op: TagEq, Value: "test"},
+				{Key: "app", Op: TagMatch, Value: "ch.*"},
+				{Key: "env", Op: TagNe, Value: "dev"},
+				{Key: "dc", Op: TagNotMatch, Value: "(a|b)"},
+			},
+			wantFound: true,
+		},
+		{
+			target: "sumSeries(seriesByTag('name=a'), seriesByTag('name=b', 'app = x'))",
+			want: []TagTerm{
+				{Key: "__name__", Op: TagEq, Value: "a"},
+				{Key: "__name__", Op: TagEq, Value: "b"},
+				{Key: "app", Op: TagEq, Value: "x"},
+			},
+			wantFound: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			got, found := ParseSeriesByTag(tt.target)
+			if found != tt.wantFound || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSeriesByTag() = %v, %v, want %v, %v", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
+
+func Test_tag1Condition(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			query: "SELECT Path FROM graphite_tagged WHERE ((Tag1='__name__=test') AND (has(Tags, 'app=ch'))) AND (Date >='2023-01-21' AND Date <= '2023-01-21') GROUP BY Path",
+			want:  "Tag1='__name__=test'",
+		},
+		{
+			query: "SELECT Path FROM graphite_tagged WHERE (Tag1 IN ('__name__=a','__name__=b')) AND (Date >='2023-01-21' AND Date <= '2023-01-21') GROUP BY Path",
+			want:  "Tag1 IN ('__name__=a','__name__=b')",
+		},
+		{
+			query: "SELECT Path FROM graphite_tagged WHERE ((Tag1 LIKE '__name__=te%' AND match(Tag1, '^__name__=te(st|xt)')) AND (Date >='2023-01-21')) GROUP BY Path",
+			want:  "Tag1 LIKE '__name__=te%'",
+		},
+		{query: "SELECT Path FROM graphite_indexd WHERE ((Level=8) AND (Path IN ('test.a')))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tag1Condition(tt.query); got != tt.want {
+				t.Errorf("tag1Condition() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTermOf(t *testing.T) {
+	terms := []TagTerm{{Key: "__name__", Op: TagEq, Value: "test"}, {Key: "app", Op: TagMatch, Value: "ch.*"}}
+	if got, ok := TermOf(terms, "Tag1 LIKE 'app=ch%'"); !ok || got != terms[1] {
+		t.Errorf("TermOf() = %v, %v, want %v", got, ok, terms[1])
+	}
+	if _, ok := TermOf(terms, "Tag1='dc=a'"); ok {
+		t.Error("TermOf() must not match")
+	}
+}
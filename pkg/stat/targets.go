@@ -0,0 +1,192 @@
+package stat
+
+import (
+	"strings"
+)
+
+// renderCacheTarget return target from render finder cache key, like 2023-01-21;2023-01-21;test.a;ttl=60
+func renderCacheTarget(cacheKey string) (string, bool) {
+	if end := strings.LastIndex(cacheKey, ";ttl="); end > 0 {
+		cacheKey = cacheKey[:end]
+	} else {
+		return "", false
+	}
+	// skip from and until dates
+	for i := 0; i < 2; i++ {
+		n := strings.IndexByte(cacheKey, ';')
+		if n == -1 {
+			return "", false
+		}
+		cacheKey = cacheKey[n+1:]
+	}
+	return cacheKey, cacheKey != ""
+}
+
+// targetIndex return index of target query with same from/until (if set in entry) or -1, if not found
+func (s *Stat) targetIndex(target string, from, until int64) int {
+	for i := range s.Queries {
+		q := &s.Queries[i]
+		if q.Query != target {
+			continue
+		}
+		if (from == 0 || q.From == from) && (until == 0 || q.Until == until) {
+			return i
+		}
+	}
+	return -1
+}
+
+// linkTarget set found metrics of target from render finder entry and link target to unlinked index queries,
+// executed (or cached) before entry
+func (s *Stat) linkTarget(e *Entry) {
+	target := e.Target
+	if target == "" {
+		var ok bool
+		if target, ok = renderCacheTarget(e.GetCache); !ok {
+			if target, ok = renderCacheTarget(e.SetCache); !ok {
+				return
+			}
+		}
+	}
+	n := s.targetIndex(target, int64(e.From), int64(e.Until))
+	if n == -1 {
+		return
+	}
+	s.Queries[n].Metrics = int64(e.Metrics)
+	for i := len(s.Index) - 1; i >= 0 && len(s.Index[i].Targets) == 0; i-- {
+		s.Index[i].Targets = []int{n}
+	}
+}
+
+// dataTargets return targets (Queries indexes) of data query, nearest by from/until (several targets for shared query)
+func (s *Stat) dataTargets(from, until int64) []int {
+	var (
+		targets []int
+		min     int64 = -1
+	)
+	for i := range s.Queries {
+		q := &s.Queries[i]
+		if q.From == 0 || q.Until == 0 {
+			continue
+		}
+		d := abs(q.From-from) + abs(q.Until-until)
+		if min == -1 || d < min {
+			min = d
+			targets = append(targets[:0], i)
+		} else if d == min {
+			targets = append(targets, i)
+		}
+	}
+	return targets
+}
+
+func abs(a int64) int64 {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func containsInt(a []int, v int) bool {
+	for _, i := range a {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitTargets split render request to stats per target. Index and data queries are attributed to linked targets,
+// shared (or not linked) queries read rows, bytes and time are divided between targets by found metrics (or equally, if metrics unknown).
+// Request-level fields (request time, status, dimensions) are copied. Request with single target is returned as is.
+func (s *Stat) SplitTargets() []*Stat {
+	if len(s.Queries) < 2 {
+		return []*Stat{s}
+	}
+	var totalMetrics int64
+	for i := range s.Queries {
+		totalMetrics += s.Queries[i].Metrics
+	}
+	// share of target in queries, linked to targets (all targets, if nil)
+	share := func(n int, targets []int) float64 {
+		var (
+			metrics int64
+			count   int
+		)
+		for i := range s.Queries {
+			if targets == nil || containsInt(targets, i) {
+				metrics += s.Queries[i].Metrics
+				count++
+			}
+		}
+		if metrics == 0 {
+			return 1 / float64(count)
+		}
+		return float64(s.Queries[n].Metrics) / float64(metrics)
+	}
+
+	stats := make([]*Stat, 0, len(s.Queries))
+	for n := range s.Queries {
+		c := *s
+		c.Queries = []Query{s.Queries[n]}
+		c.ReadRows, c.ReadBytes = 0, 0
+
+		c.IndexReadRows, c.IndexReadBytes = 0, 0
+		c.Index = make([]IndexStat, 0, 1)
+		for i := range s.Index {
+			q := s.Index[i]
+			if len(q.Targets) > 0 && !containsInt(q.Targets, n) {
+				continue
+			}
+			if k := share(n, q.Targets); k < 1 {
+				q.ReadRows = int64(float64(q.ReadRows) * k)
+				q.ReadBytes = int64(float64(q.ReadBytes) * k)
+				q.Time *= k
+			}
+			if len(q.Targets) > 0 {
+				q.Targets = []int{0}
+			}
+			c.IndexReadRows += q.ReadRows
+			c.IndexReadBytes += q.ReadBytes
+			c.Index = append(c.Index, q)
+		}
+
+		c.DataReadRows, c.DataReadBytes = 0, 0
+		c.Data = make([]DataStat, 0, 1)
+		for i := range s.Data {
+			q := s.Data[i]
+			if len(q.Targets) > 0 && !containsInt(q.Targets, n) {
+				continue
+			}
+			if k := share(n, q.Targets); k < 1 {
+				q.ReadRows = int64(float64(q.ReadRows) * k)
+				q.ReadBytes = int64(float64(q.ReadBytes) * k)
+				q.Time *= k
+				q.Points = int64(float64(q.Points) * k)
+				q.Bytes = int64(float64(q.Bytes) * k)
+				q.ParseTime *= k
+			}
+			if len(q.Targets) > 0 {
+				q.Targets = []int{0}
+			}
+			c.DataReadRows += q.ReadRows
+			c.DataReadBytes += q.ReadBytes
+			c.Data = append(c.Data, q)
+		}
+		c.ReadRows = c.IndexReadRows + c.DataReadRows
+		c.ReadBytes = c.IndexReadBytes + c.DataReadBytes
+
+		k := share(n, nil)
+		if totalMetrics > 0 {
+			c.Metrics = s.Queries[n].Metrics
+		} else {
+			c.Metrics = int64(float64(s.Metrics) * k)
+		}
+		c.Points = int64(float64(s.Points) * k)
+		c.Bytes = int64(float64(s.Bytes) * k)
+		c.DataParseTime = s.DataParseTime * k
+
+		stats = append(stats, &c)
+	}
+	return stats
+}
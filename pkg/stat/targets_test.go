@@ -0,0 +1,112 @@
+package stat
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_renderCacheTarget(t *testing.T) {
+	tests := []struct {
+		cacheKey string
+		want     string
+		wantOk   bool
+	}{
+		{cacheKey: "2023-01-21;2023-01-21;test.a;ttl=60", want: "test.a", wantOk: true},
+		{cacheKey: "2023-01-21;2023-01-21;seriesByTag('name=test');ttl=60", want: "seriesByTag('name=test')", wantOk: true},
+		{cacheKey: "1970-02-12;query=test.c*;ts=1674288000"},
+		{cacheKey: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cacheKey, func(t *testing.T) {
+			got, ok := renderCacheTarget(tt.cacheKey)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("renderCacheTarget() = (%q, %v), want (%q, %v)", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestStat_dataTargets(t *testing.T) {
+	s := &Stat{
+		Queries: []Query{
+			{Query: "test.a", From: 1674293829, Until: 1674293949},
+			{Query: "test.b", From: 1674293829 - 86400, Until: 1674293949 - 86400},
+			{Query: "test.c", From: 1674293829, Until: 1674293949},
+		},
+	}
+	if got := s.dataTargets(1674293830, 1674293949); !reflect.DeepEqual(got, []int{0, 2}) {
+		t.Errorf("dataTargets() = %v, want [0 2]", got)
+	}
+	if got := s.dataTargets(1674293830-86400, 1674293949-86400); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("dataTargets() = %v, want [1]", got)
+	}
+}
+
+func TestStat_SplitTargets(t *testing.T) {
+	s := &Stat{
+		Id: "1", RequestType: "render", Username: "test",
+		Metrics: 4, Points: 40, Bytes: 400,
+		RequestStatus: 200, RequestTime: 1, QueryTime: 1,
+		Queries: []Query{
+			{Query: "test.a", Days: 1, From: 1674293829, Until: 1674293949, Metrics: 1},
+			{Query: "test.b.*", Days: 1, From: 1674293829, Until: 1674293949, Metrics: 3},
+		},
+		ReadRows: 100 + 200 + 1000, ReadBytes: 1000 + 2000 + 10000,
+		IndexReadRows: 100 + 200, IndexReadBytes: 1000 + 2000,
+		Index: []IndexStat{
+			{Status: StatusSuccess, ReadRows: 100, ReadBytes: 1000, Time: 0.1, Days: 1, Targets: []int{0}},
+			{Status: StatusSuccess, ReadRows: 200, ReadBytes: 2000, Time: 0.2, Days: 1, Targets: []int{1}},
+		},
+		DataReadRows: 1000, DataReadBytes: 10000,
+		Data: []DataStat{
+			{Status: StatusSuccess, ReadRows: 1000, ReadBytes: 10000, Time: 0.5, Days: 1, From: 1674293830, Until: 1674293949, Targets: []int{0, 1}},
+		},
+	}
+	want := []*Stat{
+		{
+			Id: "1", RequestType: "render", Username: "test",
+			Metrics: 1, Points: 10, Bytes: 100,
+			RequestStatus: 200, RequestTime: 1, QueryTime: 1,
+			Queries:  []Query{{Query: "test.a", Days: 1, From: 1674293829, Until: 1674293949, Metrics: 1}},
+			ReadRows: 100 + 250, ReadBytes: 1000 + 2500,
+			IndexReadRows: 100, IndexReadBytes: 1000,
+			Index: []IndexStat{
+				{Status: StatusSuccess, ReadRows: 100, ReadBytes: 1000, Time: 0.1, Days: 1, Targets: []int{0}},
+			},
+			DataReadRows: 250, DataReadBytes: 2500,
+			Data: []DataStat{
+				{Status: StatusSuccess, ReadRows: 250, ReadBytes: 2500, Time: 0.125, Days: 1, From: 1674293830, Until: 1674293949, Targets: []int{0}},
+			},
+		},
+		{
+			Id: "1", RequestType: "render", Username: "test",
+			Metrics: 3, Points: 30, Bytes: 300,
+			RequestStatus: 200, RequestTime: 1, QueryTime: 1,
+			Queries:  []Query{{Query: "test.b.*", Days: 1, From: 1674293829, Until: 1674293949, Metrics: 3}},
+			ReadRows: 200 + 750, ReadBytes: 2000 + 7500,
+			IndexReadRows: 200, IndexReadBytes: 2000,
+			Index: []IndexStat{
+				{Status: StatusSuccess, ReadRows: 200, ReadBytes: 2000, Time: 0.2, Days: 1, Targets: []int{0}},
+			},
+			DataReadRows: 750, DataReadBytes: 7500,
+			Data: []DataStat{
+				{Status: StatusSuccess, ReadRows: 750, ReadBytes: 7500, Time: 0.375, Days: 1, From: 1674293830, Until: 1674293949, Targets: []int{0}},
+			},
+		},
+	}
+	got := s.SplitTargets()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Stat.SplitTargets() = %s", cmp.Diff(want, got))
+	}
+	if s.ReadRows != 1300 || len(s.Data[0].Targets) != 2 {
+		t.Errorf("Stat.SplitTargets() modify request stat")
+	}
+
+	// single target request is not splitted
+	single := &Stat{Id: "2", RequestType: "render", Queries: []Query{{Query: "test.a"}}}
+	if got := single.SplitTargets(); len(got) != 1 || got[0] != single {
+		t.Errorf("Stat.SplitTargets() = %v, want request stat", got)
+	}
+}
@@ -0,0 +1,70 @@
+package stat
+
+import "sort"
+
+// Stage is a request processing stage
+type Stage int8
+
+const (
+	StageStart Stage = iota
+	StageParse
+	StageFind
+	StageIndex
+	StageData
+	StageDataParse
+	StageReply
+)
+
+var stageStrings []string = []string{"start", "parse", "find", "index", "data", "data_parse", "reply"}
+
+func (s Stage) String() string {
+	return stageStrings[s]
+}
+
+// Event is a request timeline event
+type Event struct {
+	TimeStamp int64 // unix nano
+	Stage     Stage
+	N         int // Index or Data item number for StageIndex and StageData
+}
+
+// Offset return event offset (nanoseconds) from request start
+func (e Event) Offset(s *Stat) int64 {
+	return e.TimeStamp - s.StartTimeStamp()
+}
+
+// Timeline return request events (start, parse, find, index and data queries, data parse, reply), ordered by timestamp.
+// Stages without recorded timestamp are skipped.
+func (s *Stat) Timeline() []Event {
+	events := make([]Event, 0, len(s.Index)+len(s.Data)+5)
+	events = append(events, Event{TimeStamp: s.StartTimeStamp(), Stage: StageStart})
+	if s.ParseTimeStamp > 0 {
+		events = append(events, Event{TimeStamp: s.ParseTimeStamp, Stage: StageParse})
+	}
+	if s.FindTimeStamp > 0 {
+		events = append(events, Event{TimeStamp: s.FindTimeStamp, Stage: StageFind})
+	}
+	for i := range s.Index {
+		if s.Index[i].TimeStamp > 0 {
+			events = append(events, Event{TimeStamp: s.Index[i].TimeStamp, Stage: StageIndex, N: i})
+		}
+	}
+	for i := range s.Data {
+		if s.Data[i].TimeStamp > 0 {
+			events = append(events, Event{TimeStamp: s.Data[i].TimeStamp, Stage: StageData, N: i})
+		}
+	}
+	if s.DataParseTimeStamp > 0 {
+		events = append(events, Event{TimeStamp: s.DataParseTimeStamp, Stage: StageDataParse})
+	}
+	if s.RequestStatus > 0 {
+		events = append(events, Event{TimeStamp: s.TimeStamp, Stage: StageReply})
+	}
+
+	// events with the same timestamp are kept in stage order
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].TimeStamp < events[j].TimeStamp
+	})
+
+	return events
+}
@@ -0,0 +1,54 @@
+package stat
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStat_Timeline(t *testing.T) {
+	tests := []struct {
+		name string
+		s    *Stat
+		want []Event
+	}{
+		{
+			name: "render",
+			s: &Stat{
+				TimeStamp: 1674288343773000000, Start: 1674288343290000000,
+				ParseTimeStamp: 1674288343290000000, FindTimeStamp: 1674288343510000000, DataParseTimeStamp: 1674288343772000000,
+				RequestStatus: 200,
+				Index:         []IndexStat{{TimeStamp: 1674288343510000000}},
+				Data:          []DataStat{{TimeStamp: 1674288343772000000}, {TimeStamp: 1674288343600000000}},
+			},
+			want: []Event{
+				{TimeStamp: 1674288343290000000, Stage: StageStart},
+				{TimeStamp: 1674288343290000000, Stage: StageParse},
+				{TimeStamp: 1674288343510000000, Stage: StageFind},
+				{TimeStamp: 1674288343510000000, Stage: StageIndex},
+				{TimeStamp: 1674288343600000000, Stage: StageData, N: 1},
+				{TimeStamp: 1674288343772000000, Stage: StageData},
+				{TimeStamp: 1674288343772000000, Stage: StageDataParse},
+				{TimeStamp: 1674288343773000000, Stage: StageReply},
+			},
+		},
+		{
+			name: "not completed, without start (old checkpoint)",
+			s: &Stat{
+				TimeStamp: 1674288343510000000,
+				Index:     []IndexStat{{TimeStamp: 1674288343510000000}, {Status: StatusCached}},
+			},
+			want: []Event{
+				{TimeStamp: 1674288343510000000, Stage: StageStart},
+				{TimeStamp: 1674288343510000000, Stage: StageIndex},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.Timeline(); !cmp.Equal(got, tt.want) {
+				t.Errorf("Stat.Timeline() = %s", cmp.Diff(tt.want, got))
+			}
+		})
+	}
+}
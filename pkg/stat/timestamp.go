@@ -0,0 +1,132 @@
+package stat
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeLayout is a graphite-clickhouse log timestamp layout
+const DefaultTimeLayout = "2006-01-02T15:04:05.000-0700"
+
+// TimeLayout is a log entry timestamp format
+type TimeLayout struct {
+	name   string
+	layout string // go time layout, empty for auto detect or epoch
+	epoch  bool
+	loc    *time.Location
+}
+
+var timeLayoutAliases = map[string]string{
+	"default":     DefaultTimeLayout,
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+}
+
+// TimeLayoutStrings return supported timestamp formats (also go time layout can be used)
+func TimeLayoutStrings() []string {
+	return []string{"auto", "default", "rfc3339", "rfc3339nano", "epoch"}
+}
+
+// NewTimeLayout return timestamp format: auto (detect default, RFC3339 or epoch), default, rfc3339, rfc3339nano, epoch or go time layout.
+// Location is used for timestamps without time zone (UTC if nil).
+func NewTimeLayout(name string, loc *time.Location) (*TimeLayout, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	l := &TimeLayout{name: name, loc: loc}
+	switch name {
+	case "", "auto":
+		l.name = "auto"
+	case "epoch":
+		l.epoch = true
+	default:
+		if layout, ok := timeLayoutAliases[name]; ok {
+			l.layout = layout
+		} else if strings.ContainsAny(name, "0123456789") {
+			l.layout = name
+		} else {
+			return nil, errors.New("unknown timestamp layout: " + name)
+		}
+	}
+	return l, nil
+}
+
+func (l *TimeLayout) String() string {
+	return l.name
+}
+
+// Parse parse log timestamp and return unix nano time
+func (l *TimeLayout) Parse(v string) (int64, error) {
+	if l.epoch {
+		return parseEpoch(v)
+	}
+	if l.layout != "" {
+		t, err := time.ParseInLocation(l.layout, v, l.loc)
+		if err != nil {
+			return 0, err
+		}
+		return t.UnixNano(), nil
+	}
+
+	// auto detect
+	if isEpoch(v) {
+		return parseEpoch(v)
+	}
+	if t, err := time.ParseInLocation(DefaultTimeLayout, v, l.loc); err == nil {
+		return t.UnixNano(), nil
+	}
+	// RFC3339 with any fractional seconds
+	t, err := time.ParseInLocation(time.RFC3339Nano, v, l.loc)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixNano(), nil
+}
+
+func isEpoch(v string) bool {
+	if v == "" {
+		return false
+	}
+	for i := 0; i < len(v); i++ {
+		if (v[i] < '0' || v[i] > '9') && v[i] != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseEpoch parse unix time: seconds with fractional part or integer seconds, milliseconds, microseconds or nanoseconds (detected by digits count)
+func parseEpoch(v string) (int64, error) {
+	sec, frac, hasFrac := strings.Cut(v, ".")
+	n, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid epoch timestamp: " + v)
+	}
+	if hasFrac {
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		var ns int64
+		if frac != "" {
+			if ns, err = strconv.ParseInt(frac, 10, 64); err != nil {
+				return 0, errors.New("invalid epoch timestamp: " + v)
+			}
+			for i := len(frac); i < 9; i++ {
+				ns *= 10
+			}
+		}
+		return n*1e9 + ns, nil
+	}
+	switch {
+	case len(sec) <= 10:
+		return n * 1e9, nil
+	case len(sec) <= 13:
+		return n * 1e6, nil
+	case len(sec) <= 16:
+		return n * 1e3, nil
+	default:
+		return n, nil
+	}
+}
@@ -0,0 +1,76 @@
+package stat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeLayout_Parse(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*3600)
+	tests := []struct {
+		layout  string
+		loc     *time.Location
+		v       string
+		want    int64
+		wantErr bool
+	}{
+		{layout: "auto", v: "2023-01-21T13:06:25.761+0500", want: 1674288385761000000},
+		{layout: "auto", v: "2023-01-21T08:06:25.761123456Z", want: 1674288385761123456},
+		{layout: "auto", v: "2023-01-21T11:06:25+03:00", want: 1674288385000000000},
+		{layout: "auto", v: "1674288385", want: 1674288385000000000},
+		{layout: "auto", v: "1674288385.761", want: 1674288385761000000},
+		{layout: "auto", v: "1674288385761", want: 1674288385761000000},
+		{layout: "auto", v: "1674288385761123", want: 1674288385761123000},
+		{layout: "auto", v: "1674288385761123456", want: 1674288385761123456},
+		{layout: "auto", v: "2023-01-21 13:06:25", wantErr: true},
+		{layout: "auto", v: "", wantErr: true},
+		{layout: "default", v: "2023-01-21T13:06:25.761+0500", want: 1674288385761000000},
+		{layout: "default", v: "2023-01-21T08:06:25.761Z", wantErr: true},
+		{layout: "rfc3339nano", v: "2023-01-21T08:06:25.761Z", want: 1674288385761000000},
+		{layout: "epoch", v: "1674288385.5", want: 1674288385500000000},
+		{layout: "epoch", v: "2023-01-21T08:06:25.761Z", wantErr: true},
+		{layout: "2006-01-02 15:04:05", v: "2023-01-21 08:06:25", want: 1674288385000000000},
+		{layout: "2006-01-02 15:04:05", loc: msk, v: "2023-01-21 11:06:25", want: 1674288385000000000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.layout+"#"+tt.v, func(t *testing.T) {
+			l, err := NewTimeLayout(tt.layout, tt.loc)
+			if err != nil {
+				t.Fatalf("NewTimeLayout() error = %v", err)
+			}
+			got, err := l.Parse(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TimeLayout.Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("TimeLayout.Parse() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTimeLayout_invalid(t *testing.T) {
+	if _, err := NewTimeLayout("iso", nil); err == nil {
+		t.Error("NewTimeLayout() must fail for unknown layout")
+	}
+}
+
+func TestProcessor_EntryProcess_epoch(t *testing.T) {
+	p := NewProcessor()
+	var e Entry
+	if err := e.Unmarshal([]byte(`{"level":"INFO","timestamp":1674288385.761,"logger":"http","message":"access","request_id":"3e2aab2b6f7a5a76",` +
+		`"time":0.001,"method":"GET","url":"/metrics/find/?format=carbonapi_v3_pb","status":200}`)); err != nil {
+		t.Fatal(err)
+	}
+	queries := make(map[string]*Stat)
+	id, err := p.EntryProcess(&e, queries)
+	if err != nil {
+		t.Fatalf("Processor.EntryProcess() error = %v", err)
+	}
+	if id != "3e2aab2b6f7a5a76" {
+		t.Fatalf("Processor.EntryProcess() = %q", id)
+	}
+	if got := queries[id].TimeStamp; got != 1674288385761000000 {
+		t.Errorf("Stat.TimeStamp = %d, want %d", got, 1674288385761000000)
+	}
+}
@@ -13,7 +13,7 @@ func GetTop(queries map[string]*stat.Stat, n int, sortKey stat.Sort, from, until
 		// check for completed record
 		if s.RequestStatus > 0 {
 			add := true
-			if from > 0 && s.TimeStamp < from {
+			if from > 0 && s.StartTimeStamp() < from {
 				add = false
 			}
 			if add && until > 0 && s.TimeStamp >= until {
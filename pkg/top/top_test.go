@@ -118,6 +118,20 @@ func Test_GetTop(t *testing.T) {
 				{Id: "2", RequestStatus: 504, RequestTime: 30, QueryTime: 30, TimeStamp: 1674886980 * 1e9},
 			},
 		},
+		{
+			name: "Top from exclude started before",
+			queries: map[string]*stat.Stat{
+				"1": {Id: "1", RequestStatus: 504, RequestTime: 30, QueryTime: 30, Start: 1674886960 * 1e9, TimeStamp: 1674886990 * 1e9},
+				"2": {Id: "2", RequestStatus: 504, RequestTime: 30, QueryTime: 30, Start: 1674886980 * 1e9, TimeStamp: 1674887010 * 1e9},
+			},
+			n:     4,
+			key:   stat.SortDataReadRows,
+			from:  1674886980 * 1e9,
+			until: 1674887060 * 1e9,
+			want: []*stat.Stat{
+				{Id: "2", RequestStatus: 504, RequestTime: 30, QueryTime: 30, Start: 1674886980 * 1e9, TimeStamp: 1674887010 * 1e9},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
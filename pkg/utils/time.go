@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var timeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseTime parse absolute or relative time.
+//
+// Absolute time is RFC3339 or 2006-01-02[T15:04[:05]] in loc (UTC if nil).
+// Relative time is now, now-15m, now+1h or offset from now like -2h.
+// Empty string return zero time.
+func ParseTime(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	rel := s
+	if strings.HasPrefix(rel, "now") {
+		rel = rel[3:]
+		if rel == "" {
+			return now, nil
+		}
+	}
+	if rel[0] == '-' || rel[0] == '+' {
+		d, err := time.ParseDuration(rel)
+		if err != nil {
+			return time.Time{}, errors.New("invalid relative time: " + s)
+		}
+		return now.Add(d), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid time: " + s)
+}
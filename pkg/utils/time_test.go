@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2023, 1, 21, 13, 0, 0, 0, time.UTC)
+	msk := time.FixedZone("MSK", 3*3600)
+	tests := []struct {
+		s       string
+		loc     *time.Location
+		want    time.Time
+		wantErr bool
+	}{
+		{s: "", want: time.Time{}},
+		{s: "now", want: now},
+		{s: "now-15m", want: now.Add(-15 * time.Minute)},
+		{s: "now+1h", want: now.Add(time.Hour)},
+		{s: "-2h", want: now.Add(-2 * time.Hour)},
+		{s: "-1h30m", want: now.Add(-90 * time.Minute)},
+		{s: "2023-01-21T10:05:00", want: time.Date(2023, 1, 21, 10, 5, 0, 0, time.UTC)},
+		{s: "2023-01-21 10:05:00", want: time.Date(2023, 1, 21, 10, 5, 0, 0, time.UTC)},
+		{s: "2023-01-21 10:05", want: time.Date(2023, 1, 21, 10, 5, 0, 0, time.UTC)},
+		{s: "2023-01-21", want: time.Date(2023, 1, 21, 0, 0, 0, 0, time.UTC)},
+		{s: "2023-01-21T10:05:00", loc: msk, want: time.Date(2023, 1, 21, 7, 5, 0, 0, time.UTC)},
+		{s: "2023-01-21T10:05:00+05:00", loc: msk, want: time.Date(2023, 1, 21, 5, 5, 0, 0, time.UTC)},
+		{s: "now-", wantErr: true},
+		{s: "-2x", wantErr: true},
+		{s: "yesterday", wantErr: true},
+		{s: "2023-01-21T10", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseTime(tt.s, now, tt.loc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}